@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/jeffwilliams/basejump/jump"
+)
+
+// start begins a new jump, canceling the jump that is in progress if there is one,
+// and returns the context of the new jump. `cancel` must be called when the jump is done.
+func (s *jumpState) start() (ctx context.Context, cancel context.CancelFunc) {
+	ctx, cancel = context.WithCancel(context.Background())
+
+	s.cancelMu.Lock()
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.cancel = cancel
+	s.cancelMu.Unlock()
+	return
+}
+
+// run makes a jump using `f` in the background, so that nvim isn't blocked while it is
+// made. Starting a jump cancels the one in progress. Errors are echoed.
+func (a Basejump) run(f func(n Basejump) error) {
+	ctx, cancel := a.state.start()
+	go func() {
+		defer logPanic()
+		defer cancel()
+
+		a.state.mu.Lock()
+		defer a.state.mu.Unlock()
+
+		n := a.withConfig()
+		n.ctx = ctx
+		err := f(n)
+		if ctx.Err() != nil {
+			trace(n, "trace: jump canceled: %v", err)
+			return
+		}
+		if err != nil && err != errJumpCanceled {
+			logger.Error("jump failed", "err", err)
+			// Returning an error would print too much overdramatic red text
+			n.Echom("error: %v", err)
+		}
+	}()
+}
+
+// context returns the context of the jump being made.
+func (n Basejump) context() context.Context {
+	if n.ctx == nil {
+		return context.Background()
+	}
+	return n.ctx
+}
+
+// statContext returns a context for a filesystem operation made during the jump, which
+// times out after g:basejump_stat_timeout milliseconds.
+func (n Basejump) statContext() (ctx context.Context, cancel context.CancelFunc) {
+	timeout := n.config().StatTimeout
+	return context.WithTimeout(n.context(), time.Duration(timeout)*time.Millisecond)
+}
+
+// pathExists returns true if `path` exists. It returns an error if checking takes longer
+// than g:basejump_stat_timeout, or the jump is canceled.
+func (n Basejump) pathExists(path string) (exists bool, err error) {
+	ctx, cancel := n.statContext()
+	defer cancel()
+
+	exists, err = jump.PathExistsContext(ctx, path)
+	return exists, n.statError(err, path)
+}
+
+// statError describes the error `err` that ended checking the path `path`.
+func (n Basejump) statError(err error, path string) error {
+	if err == context.DeadlineExceeded && n.context().Err() == nil {
+		return fmt.Errorf("timed out checking %s", path)
+	}
+	return err
+}
+
+// firstExistingCandidate returns the first of `candidates` that is a URL or a path that
+// exists, or false if there is none. Relative paths are made absolute using `abs`. The
+// paths are checked in parallel.
+func (n Basejump) firstExistingCandidate(candidates []candidate, abs func(fpath string) (string, error)) (c candidate, ok bool, err error) {
+	paths := make([]string, 0, len(candidates))
+	for _, cand := range candidates {
+		if cand.target != nil {
+			path, perr := abs(cand.target.Path)
+			if perr != nil {
+				path = ""
+			}
+			paths = append(paths, path)
+			continue
+		}
+
+		text := cand.text
+		url, uerr := url.Parse(text)
+		if uerr == nil {
+			if url.Scheme == "file" {
+				paths = append(paths, url.Path)
+				continue
+			} else if url.Scheme == "http" || url.Scheme == "https" {
+				// Remote URLs are assumed to exist, so later candidates needn't be checked.
+				break
+			}
+		}
+
+		// A candidate that isn't a valid path is checked as the empty path, which doesn't exist.
+		path, _, _, perr := jump.ParsePathText(text)
+		if perr == nil {
+			path, perr = abs(path)
+		}
+		if perr != nil {
+			path = ""
+		}
+		paths = append(paths, path)
+	}
+
+	ctx, cancel := n.statContext()
+	defer cancel()
+
+	i, err := jump.FirstExisting(ctx, paths)
+	if err != nil {
+		return c, false, n.statError(err, "the selected paths")
+	}
+
+	switch {
+	case i >= 0:
+		return candidates[i], true, nil
+	case len(paths) < len(candidates):
+		// The URL that ended the paths
+		return candidates[len(paths)], true, nil
+	}
+	return
+}
@@ -0,0 +1,44 @@
+" Custom resolvers find locations in text that basejump doesn't understand by
+" itself. They are kept here rather than in plugin/basejump.vim so that they
+" can be registered from init.vim or init.lua before plugins are loaded.
+let s:resolvers = {}
+
+" Register the function a:Resolver as the custom resolver a:name, replacing
+" any resolver already registered under that name. a:Resolver may be a
+" Funcref or a Lua function. It is called with the text of a line and the
+" 1-based byte column of the cursor in it, and returns a list of candidate
+" locations. A candidate is either text for basejump to parse, like
+" 'file.go:12:3', or a dictionary with the keys 'path', 'line' and 'col'.
+function! basejump#register_resolver(name, Resolver) abort
+  let s:resolvers[a:name] = a:Resolver
+endfunction
+
+function! basejump#unregister_resolver(name) abort
+  if has_key(s:resolvers, a:name)
+    call remove(s:resolvers, a:name)
+  endif
+endfunction
+
+" Call each custom resolver, in order of name, and return all of the
+" candidates they found as dictionaries. Strings are converted into
+" dictionaries with the key 'text', and each is given the key 'resolver'
+" naming the resolver that found it.
+function! basejump#run_resolvers(text, col) abort
+  let candidates = []
+  for name in sort(keys(s:resolvers))
+    let result = call(s:resolvers[name], [a:text, a:col])
+    if type(result) != v:t_list
+      let result = [result]
+    endif
+    for candidate in result
+      if type(candidate) == v:t_string
+        let candidate = {'text': candidate}
+      endif
+      " Anything else, like the v:null a Lua function returns for nil, is no candidate
+      if type(candidate) == v:t_dict
+        call add(candidates, extend({'resolver': name}, candidate))
+      endif
+    endfor
+  endfor
+  return candidates
+endfunction
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/jeffwilliams/basejump/diff"
+	"github.com/jeffwilliams/basejump/jump"
+	"github.com/jeffwilliams/basejump/server"
+)
+
+// subcommands are run instead of the plugin host when their name is the first argument.
+var subcommands = map[string]func(args []string) error{
+	"open":    openCommand,
+	"resolve": resolveCommand,
+	"diffpos": diffposCommand,
+}
+
+// defaultPathChars is the default value of g:basejump_pathchars.
+const defaultPathChars = "-~/[a-z][A-Z].:[0-9]_"
+
+// openCommand asks the basejump listening on a socket to open text like main.go:12.
+func openCommand(args []string) error {
+	fs := flag.NewFlagSet("open", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: basejump open [flags] TEXT\n\n"+
+			"Open TEXT, a path optionally followed by :line and :col, or a URL, in the nvim\n"+
+			"instance of the basejump listening on the socket.\n\n")
+		fs.PrintDefaults()
+	}
+
+	socket := os.Getenv("BASEJUMP_SOCKET")
+	if socket == "" {
+		socket = server.DefaultPath()
+	}
+	cwd, _ := os.Getwd()
+
+	optSocket := fs.String("socket", socket, "the socket basejump listens on. Defaults to $BASEJUMP_SOCKET if it is set")
+	optCwd := fs.String("cwd", cwd, "the directory that a relative path is relative to")
+	optMode := fs.String("mode", "", "the open mode. Defaults to g:basejump_openmode")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("no text to open")
+	}
+
+	resp, err := server.Send(*optSocket, server.Request{
+		Text:   strings.Join(fs.Args(), " "),
+		Cwd:    *optCwd,
+		Method: *optMode,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(formatLocation(resp.Path, resp.Line, resp.Col))
+	return nil
+}
+
+// formatLocation formats a path, line and column like path:line:col, leaving out a
+// line or column that is 0.
+func formatLocation(path string, line, col int) string {
+	if line > 0 {
+		path = fmt.Sprintf("%s:%d", path, line)
+		if col > 0 {
+			path = fmt.Sprintf("%s:%d", path, col)
+		}
+	}
+	return path
+}
+
+// resolveCommand prints what the path under a column of a line of text refers to, using
+// the same rules as OpenPathUnderCursor.
+func resolveCommand(args []string) error {
+	fs := flag.NewFlagSet("resolve", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: basejump resolve [flags] TEXT\n\n"+
+			"Find the path or URL at a column of the line TEXT, and print what it refers to as JSON.\n\n")
+		fs.PrintDefaults()
+	}
+
+	cwd, _ := os.Getwd()
+
+	optCol := fs.Int("col", 1, "the 1-based byte column of the cursor in TEXT")
+	optCwd := fs.String("cwd", cwd, "the directory that a relative path is relative to")
+	optPathChars := fs.String("pathchars", defaultPathChars, "the characters that may be part of a path, like g:basejump_pathchars")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("no text to resolve")
+	}
+
+	r, err := resolveLine(strings.Join(fs.Args(), " "), *optCol, *optPathChars, func(fpath string) (string, error) {
+		return jump.AbsPathRelDir(fpath, *optCwd), nil
+	})
+	if err != nil {
+		return err
+	}
+	if r.Path != "" {
+		r.Exists = jump.PathExists(r.Path)
+	}
+
+	return printJSON(r)
+}
+
+// diffposCommand prints the file and line that a line of a patch file refers to, like
+// OpenLineFromDiff does for the line under the cursor.
+func diffposCommand(args []string) error {
+	fs := flag.NewFlagSet("diffpos", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: basejump diffpos [flags] FILE LINE\n\n"+
+			"Print the file and line in the modified file that line LINE of the unified diff FILE\n"+
+			"refers to as JSON.\n\n")
+		fs.PrintDefaults()
+	}
+
+	cwd, _ := os.Getwd()
+	optCwd := fs.String("cwd", cwd, "the directory that the paths in the diff are relative to")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return fmt.Errorf("expected a file and a line")
+	}
+
+	line, err := strconv.Atoi(fs.Arg(1))
+	if err != nil {
+		return fmt.Errorf("invalid line '%s'", fs.Arg(1))
+	}
+
+	g, err := diff.NewFileLineGetter(fs.Arg(0), line)
+	if err != nil {
+		return err
+	}
+
+	path, lineNo, err := diff.CalcFileAndLine(g, func(path string) bool {
+		return jump.PathExists(jump.AbsPathRelDir(path, *optCwd))
+	})
+	if err != nil {
+		return err
+	}
+
+	return printJSON(struct {
+		Path string `json:"path"`
+		Line int    `json:"line"`
+	}{jump.AbsPathRelDir(path, *optCwd), lineNo})
+}
+
+func printJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jeffwilliams/basejump/jump"
+)
+
+func TestResolveLine(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "main.go"), nil, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	abs := func(fpath string) (string, error) {
+		return jump.AbsPathRelDir(fpath, dir), nil
+	}
+
+	tests := []struct {
+		name string
+		text string
+		col  int
+		want resolution
+	}{
+		{
+			name: "relative path",
+			text: "error at main.go:10:5: undefined",
+			col:  12,
+			want: resolution{Text: "main.go:10:5:", Start: 10, End: 23, Path: filepath.Join(dir, "main.go"),
+				Line: 10, Col: 5, ResolvedAs: jump.ResolvedRelativePath, Exists: true},
+		},
+		{
+			name: "absolute path after multibyte text",
+			text: "→ /nonexistent/file.c:3",
+			col:  6,
+			want: resolution{Text: "/nonexistent/file.c:3", Start: 5, End: 26, Path: "/nonexistent/file.c",
+				Line: 3, ResolvedAs: jump.ResolvedAbsolutePath},
+		},
+		{
+			name: "url",
+			text: "see https://example.com/x for details",
+			col:  10,
+			want: resolution{Text: "https://example.com/x", Start: 5, End: 26, URL: "https://example.com/x",
+				ResolvedAs: jump.ResolvedURL},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := resolveLine(tc.text, tc.col, defaultPathChars, abs)
+			if err != nil {
+				t.Fatalf("Got error: %v", err)
+			}
+			if r.Path != "" {
+				r.Exists = jump.PathExists(r.Path)
+			}
+			if r != tc.want {
+				t.Fatalf("Expected %+v but got %+v", tc.want, r)
+			}
+		})
+	}
+
+	_, err = resolveLine("two words", 4, defaultPathChars, abs)
+	if err == nil {
+		t.Fatalf("Expected an error for a column that is not in a path but got none")
+	}
+}
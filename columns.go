@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// ColumnUnit describes how a column number counts its way along a line.
+// Vim works in byte columns (getpos, cursor), but the column numbers embedded in
+// text like file.go:10:5 are produced by many different tools that count differently.
+type ColumnUnit string
+
+const (
+	// ColumnBytes counts bytes, starting at 1. This is what vim and most Go tools use.
+	ColumnBytes ColumnUnit = "byte"
+	// ColumnChars counts characters (unicode code points), starting at 1.
+	ColumnChars ColumnUnit = "char"
+	// ColumnUTF16 counts UTF-16 code units, starting at 1, like LSP positions.
+	ColumnUTF16 ColumnUnit = "utf16"
+	// ColumnDisplay counts screen cells with tabs expanded and wide characters
+	// taking two cells, starting at 1, like the columns emitted by many compilers.
+	ColumnDisplay ColumnUnit = "display"
+)
+
+func parseColumnUnit(s string) (ColumnUnit, error) {
+	switch u := ColumnUnit(s); u {
+	case ColumnBytes, ColumnChars, ColumnUTF16, ColumnDisplay:
+		return u, nil
+	}
+	return ColumnBytes, fmt.Errorf("invalid column unit '%s'. Expected one of byte, char, utf16 or display", s)
+}
+
+// byteColToCharIndex converts the 1-based byte column `col` in `s` to the 0-based index
+// of the character that contains that byte. Columns past the end of `s` result in an
+// index past the last character.
+func byteColToCharIndex(s string, col int) int {
+	if col < 1 {
+		return -1
+	}
+	if col > len(s) {
+		return utf8.RuneCountInString(s) + col - len(s) - 1
+	}
+	return utf8.RuneCountInString(s[:clampByteIndex(s, col-1)])
+}
+
+// toByteCol converts the 1-based column `col`, counted in `unit`, to a 1-based byte column in
+// `s`. Columns that lie in the middle of a character are moved to the start of the character, and
+// columns past the end of `s` are extended as if `s` were padded with single byte characters.
+func toByteCol(s string, col int, unit ColumnUnit, tabstop int) int {
+	if col < 1 {
+		return col
+	}
+
+	if unit == ColumnBytes {
+		if col > len(s) {
+			return col
+		}
+		return clampByteIndex(s, col-1) + 1
+	}
+
+	pos := 1
+	for i, r := range s {
+		w := 1
+		switch unit {
+		case ColumnUTF16:
+			if r >= 0x10000 {
+				w = 2
+			}
+		case ColumnDisplay:
+			w = runeWidth(r, pos, tabstop)
+		}
+
+		if col < pos+w {
+			return i + 1
+		}
+		pos += w
+	}
+	return len(s) + col - pos + 1
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestByteColToCharIndex(t *testing.T) {
+	tests := []struct {
+		input  string
+		col    int
+		output int
+	}{
+		{"abc", 1, 0},
+		{"abc", 3, 2},
+		{"abc", 5, 4},
+		{"éa", 1, 0},
+		{"éa", 2, 0},
+		{"éa", 3, 1},
+		{"日本/file.go", 7, 2},
+		{"abc", 0, -1},
+	}
+	for _, tc := range tests {
+		t.Run(fmt.Sprintf("%s[%d]", tc.input, tc.col), func(t *testing.T) {
+			r := byteColToCharIndex(tc.input, tc.col)
+			if r != tc.output {
+				t.Fatalf("expected %d but got %d", tc.output, r)
+			}
+		})
+	}
+}
+
+func TestToByteCol(t *testing.T) {
+	tests := []struct {
+		input  string
+		col    int
+		unit   ColumnUnit
+		output int
+	}{
+		{"abc", 2, ColumnBytes, 2},
+		{"éa", 2, ColumnBytes, 1},
+		{"éa", 9, ColumnBytes, 9},
+		{"éab", 2, ColumnChars, 3},
+		{"éab", 3, ColumnChars, 4},
+		{"éab", 5, ColumnChars, 6},
+		{"😀ab", 3, ColumnUTF16, 5},
+		{"😀ab", 2, ColumnUTF16, 1},
+		{"😀ab", 2, ColumnChars, 5},
+		{"\tx", 9, ColumnDisplay, 2},
+		{"\tx", 4, ColumnDisplay, 1},
+		{"ab\tx", 9, ColumnDisplay, 4},
+		{"é\tx", 9, ColumnDisplay, 4},
+		{"日本x", 5, ColumnDisplay, 7},
+		{"日本x", 2, ColumnDisplay, 1},
+		{"😀\tx", 9, ColumnDisplay, 6},
+	}
+	for _, tc := range tests {
+		t.Run(fmt.Sprintf("%s[%d %s]", tc.input, tc.col, tc.unit), func(t *testing.T) {
+			r := toByteCol(tc.input, tc.col, tc.unit, 8)
+			if r != tc.output {
+				t.Fatalf("expected %d but got %d", tc.output, r)
+			}
+		})
+	}
+}
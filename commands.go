@@ -0,0 +1,146 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/jeffwilliams/basejump/logging"
+)
+
+func isOpenMode(s string) bool {
+	for _, m := range openModes {
+		if s == m {
+			return true
+		}
+	}
+	return false
+}
+
+// splitModeArg splits the arguments of a command into an optional leading open
+// mode, and the text after it.
+func splitModeArg(args []string) (method, text string) {
+	if len(args) > 0 && isOpenMode(args[0]) {
+		method, args = args[0], args[1:]
+	}
+	return method, strings.Join(args, " ")
+}
+
+// command handles :[range]Basejump [mode] [text]. If text is given it is opened,
+// otherwise the path under the cursor is. With a range from visual mode the selected
+// path is opened, and with any other range each line in the range is a candidate like
+// in linewise visual mode. `rangeCount` is the number of items in the range given.
+func (a Basejump) command(args []string, rng [2]int, rangeCount int) {
+	defer logPanic()
+
+	a.run(func(n Basejump) error {
+		method, text := splitModeArg(args)
+		if method == "" {
+			method = n.config().OpenMode
+		}
+
+		if text != "" {
+			c, err := n.resolveTexts([]string{expandHome(text)}, -1, "")
+			if err != nil {
+				return err
+			}
+			return n.openCandidate(c, method, -1)
+		}
+
+		if rangeCount == 0 {
+			cur, err := n.cursorState()
+			if err != nil {
+				return err
+			}
+			return n.OpenPathUnderCursor(cur, method)
+		}
+
+		var visual [2]int
+		err := n.nvim().Eval(`[line("'<"), line("'>")]`, &visual)
+		if err != nil {
+			return err
+		}
+		if visual == rng {
+			s, err := n.selectionState()
+			if err != nil {
+				return err
+			}
+			return n.OpenSelectedPath(s, method)
+		}
+		return n.OpenPathInLines(rng[0], rng[1], method)
+	})
+}
+
+// diffCommand handles :BasejumpDiff [mode].
+func (a Basejump) diffCommand(args []string) {
+	defer logPanic()
+
+	a.run(func(n Basejump) error {
+		method, _ := splitModeArg(args)
+		if method == "" {
+			method = n.config().OpenMode
+		}
+		return n.OpenLineFromDiff(method)
+	})
+}
+
+// Complete completes the arguments of the basejump commands. The first argument may
+// be an open mode, and the other arguments are file names.
+func (n Basejump) Complete(args []interface{}) (matches []string, err error) {
+	defer logPanic()
+
+	var lead, cmdline string
+	if len(args) > 1 {
+		lead, _ = args[0].(string)
+		cmdline, _ = args[1].(string)
+	}
+
+	words := strings.Fields(cmdline)
+	if len(words) > 0 && strings.HasSuffix(words[0], "BasejumpLog") {
+		// :BasejumpLog only takes a log level
+		return completeLevels(lead, cmdline), nil
+	}
+
+	matches, _ = completeModes(lead, cmdline)
+	if len(words) > 0 && strings.HasSuffix(words[0], "BasejumpDiff") {
+		// :BasejumpDiff only takes a mode
+		return
+	}
+
+	var files []string
+	err = n.nvim().Call("getcompletion", &files, lead, "file")
+	matches = append(matches, files...)
+	return
+}
+
+// completeLevels returns the log levels that start with `lead` if the argument being
+// completed in the command line `cmdline` is the first one.
+func completeLevels(lead, cmdline string) (levels []string) {
+	if _, first := completeModes(lead, cmdline); !first {
+		return
+	}
+	for _, l := range logging.LevelNames {
+		if strings.HasPrefix(l, lead) {
+			levels = append(levels, l)
+		}
+	}
+	return
+}
+
+// completeModes returns the open modes that start with `lead` if the argument being completed
+// in the command line `cmdline` is the first one. Otherwise `first` is false.
+func completeModes(lead, cmdline string) (modes []string, first bool) {
+	words := strings.Fields(cmdline)
+	// When an argument is being started the lead is empty and isn't one of the words
+	if lead != "" {
+		words = words[:len(words)-1]
+	}
+	if len(words) > 1 {
+		return nil, false
+	}
+
+	for _, m := range openModes {
+		if strings.HasPrefix(m, lead) {
+			modes = append(modes, m)
+		}
+	}
+	return modes, true
+}
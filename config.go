@@ -0,0 +1,429 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"github.com/jeffwilliams/basejump/logging"
+)
+
+// config is basejump's configuration. It is read from these places, each overriding
+// the ones before it:
+//
+//	the variables g:basejump_<option>, like g:basejump_openmode
+//	the dictionary g:basejump, like {'openmode': 'tab'}
+//	the dictionary g:basejump.filetypes[&filetype] for the current buffer's filetype
+//	the dictionary b:basejump
+type config struct {
+	PathChars         string
+	OpenNonexistent   bool
+	Browsers          []string
+	OpenMode          string
+	AutoMinWidth      int
+	AutoMinHeight     int
+	AutoSplitLargest  bool
+	ColumnUnit        ColumnUnit
+	TerminalUseEditor bool
+	TerminalKeepFocus bool
+	PreviewContext    int
+	Tagstack          bool
+	History           bool
+	HistorySize       int
+	TargetServer      string
+	// StatTimeout and ResolverTimeout are in milliseconds
+	StatTimeout      int
+	ResolverCommands [][]string
+	ResolverTimeout  int
+
+	// Listen, LogLevel and LogFile are read by the plugin script when it starts basejump,
+	// and are passed as flags. They are parsed here so that mistakes are reported.
+	Listen   string
+	LogLevel slog.Level
+	LogFile  string
+}
+
+// defaultConfig returns the configuration used when nothing is set.
+func defaultConfig() config {
+	return config{
+		PathChars:       defaultPathChars,
+		OpenNonexistent: true,
+		Browsers:        []string{"elinks", "w3m", "links", "lynx"},
+		OpenMode:        openBySplit,
+		AutoMinWidth:    80,
+		AutoMinHeight:   10,
+		ColumnUnit:      ColumnBytes,
+		PreviewContext:  5,
+		Tagstack:        true,
+		History:         true,
+		HistorySize:     1000,
+		StatTimeout:     2000,
+		ResolverTimeout: 1000,
+		LogLevel:        slog.LevelInfo,
+	}
+}
+
+// configOptions sets each option of a config from a vim value, checking that the value is valid.
+var configOptions = map[string]func(c *config, v interface{}) error{
+	"pathchars": func(c *config, v interface{}) (err error) {
+		c.PathChars, err = stringValue(v)
+		return
+	},
+	"open_nonexistent": func(c *config, v interface{}) (err error) {
+		c.OpenNonexistent, err = boolValue(v)
+		return
+	},
+	"browsers": func(c *config, v interface{}) (err error) {
+		c.Browsers, err = stringListValue(v)
+		return
+	},
+	"openmode": func(c *config, v interface{}) (err error) {
+		s, err := stringValue(v)
+		if err != nil {
+			return
+		}
+		if s == "" {
+			s = openBySplit
+		}
+		if !isOpenMode(s) {
+			return fmt.Errorf("invalid open mode '%s'. Expected one of %v", s, openModes)
+		}
+		c.OpenMode = s
+		return
+	},
+	"auto_min_width": func(c *config, v interface{}) (err error) {
+		c.AutoMinWidth, err = intValue(v, 1)
+		return
+	},
+	"auto_min_height": func(c *config, v interface{}) (err error) {
+		c.AutoMinHeight, err = intValue(v, 1)
+		return
+	},
+	"auto_split_largest": func(c *config, v interface{}) (err error) {
+		c.AutoSplitLargest, err = boolValue(v)
+		return
+	},
+	"column_unit": func(c *config, v interface{}) (err error) {
+		s, err := stringValue(v)
+		if err != nil {
+			return
+		}
+		unit, err := parseColumnUnit(s)
+		if err == nil {
+			c.ColumnUnit = unit
+		}
+		return
+	},
+	"terminal_use_editor": func(c *config, v interface{}) (err error) {
+		c.TerminalUseEditor, err = boolValue(v)
+		return
+	},
+	"terminal_keep_focus": func(c *config, v interface{}) (err error) {
+		c.TerminalKeepFocus, err = boolValue(v)
+		return
+	},
+	"preview_context": func(c *config, v interface{}) (err error) {
+		c.PreviewContext, err = intValue(v, 0)
+		return
+	},
+	"tagstack": func(c *config, v interface{}) (err error) {
+		c.Tagstack, err = boolValue(v)
+		return
+	},
+	"history": func(c *config, v interface{}) (err error) {
+		c.History, err = boolValue(v)
+		return
+	},
+	"history_size": func(c *config, v interface{}) (err error) {
+		c.HistorySize, err = intValue(v, 1)
+		return
+	},
+	"target_server": func(c *config, v interface{}) (err error) {
+		c.TargetServer, err = stringValue(v)
+		return
+	},
+	"stat_timeout": func(c *config, v interface{}) (err error) {
+		c.StatTimeout, err = intValue(v, 1)
+		return
+	},
+	"resolver_commands": func(c *config, v interface{}) (err error) {
+		list, ok := v.([]interface{})
+		if !ok {
+			return fmt.Errorf("expected a list but got %v", v)
+		}
+		commands := make([][]string, 0, len(list))
+		for i, command := range list {
+			argv, err := commandArgv(command)
+			if err != nil {
+				return fmt.Errorf("[%d]: %v", i, err)
+			}
+			commands = append(commands, argv)
+		}
+		c.ResolverCommands = commands
+		return
+	},
+	"resolver_timeout": func(c *config, v interface{}) (err error) {
+		c.ResolverTimeout, err = intValue(v, 1)
+		return
+	},
+	"listen": func(c *config, v interface{}) (err error) {
+		c.Listen, err = stringValue(v)
+		return
+	},
+	"log_level": func(c *config, v interface{}) (err error) {
+		s, err := stringValue(v)
+		if err != nil {
+			return
+		}
+		level, err := logging.ParseLevel(s)
+		if err == nil {
+			c.LogLevel = level
+		}
+		return
+	},
+	"log_file": func(c *config, v interface{}) (err error) {
+		c.LogFile, err = stringValue(v)
+		return
+	},
+}
+
+// filetypesKey is the key of g:basejump that holds the per-filetype configuration.
+const filetypesKey = "filetypes"
+
+// rawConfig is the configuration as it is set in vim, before it is parsed.
+type rawConfig struct {
+	// Legacy holds the g:basejump_<option> variables, keyed by their names without the prefix
+	Legacy   map[string]interface{} `msgpack:"legacy"`
+	Global   interface{}            `msgpack:"global"`
+	Buffer   interface{}            `msgpack:"buffer"`
+	Filetype string                 `msgpack:"filetype"`
+}
+
+// rawConfigExpr is evaluated to read a rawConfig in one round trip. Only the variables
+// of options are read, so that others like g:basejump_event, which changes on every jump,
+// don't make the configuration look changed.
+var rawConfigExpr = func() string {
+	names := make([]string, 0, len(configOptions))
+	for _, key := range sortedOptionKeys() {
+		names = append(names, "'basejump_"+key+"'")
+	}
+	return `{` +
+		`'legacy': filter(copy(g:), {k -> index([` + strings.Join(names, ", ") + `], k) >= 0}), ` +
+		`'global': get(g:, 'basejump', {}), ` +
+		`'buffer': get(b:, 'basejump', {}), ` +
+		`'filetype': &filetype}`
+}()
+
+// parseConfig parses `raw` into a config. Invalid values are described in `errs` and
+// ignored, so that a mistake in one option doesn't stop basejump from working.
+func parseConfig(raw rawConfig) (c config, errs []error) {
+	c = defaultConfig()
+
+	for _, name := range sortedKeys(raw.Legacy) {
+		key := strings.TrimPrefix(name, "basejump_")
+		if _, ok := configOptions[key]; !ok {
+			continue
+		}
+		if err := c.set(key, raw.Legacy[name]); err != nil {
+			errs = append(errs, fmt.Errorf("g:%s: %v", name, err))
+		}
+	}
+
+	global, err := dictValue(raw.Global)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("g:basejump: %v", err))
+	}
+	errs = append(errs, c.apply(global, "g:basejump", filetypesKey)...)
+
+	if ft, ok := global[filetypesKey]; ok {
+		filetypes, err := dictValue(ft)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("g:basejump.%s: %v", filetypesKey, err))
+		}
+		if raw.Filetype != "" && filetypes[raw.Filetype] != nil {
+			source := fmt.Sprintf("g:basejump.%s.%s", filetypesKey, raw.Filetype)
+			overrides, err := dictValue(filetypes[raw.Filetype])
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %v", source, err))
+			}
+			errs = append(errs, c.apply(overrides, source)...)
+		}
+	}
+
+	buffer, err := dictValue(raw.Buffer)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("b:basejump: %v", err))
+	}
+	errs = append(errs, c.apply(buffer, "b:basejump")...)
+	return
+}
+
+// apply sets the options in the dictionary `dict`, which is named `source` in errors.
+// Keys in `skip` are ignored.
+func (c *config) apply(dict map[string]interface{}, source string, skip ...string) (errs []error) {
+outer:
+	for _, key := range sortedKeys(dict) {
+		for _, s := range skip {
+			if key == s {
+				continue outer
+			}
+		}
+
+		if _, ok := configOptions[key]; !ok {
+			errs = append(errs, fmt.Errorf("%s: unknown option '%s'", source, key))
+			continue
+		}
+		if err := c.set(key, dict[key]); err != nil {
+			errs = append(errs, fmt.Errorf("%s.%s: %v", source, key, err))
+		}
+	}
+	return
+}
+
+// set sets the option `key` to the vim value `v`. If the value is invalid the option is
+// left as it was.
+func (c *config) set(key string, v interface{}) error {
+	next := *c
+	err := configOptions[key](&next, v)
+	if err == nil {
+		*c = next
+	}
+	return err
+}
+
+// config returns the configuration. A jump reads it once when it starts, so that it
+// doesn't change when the jump opens a buffer with different settings. The parsed
+// configuration is cached until the settings change, and invalid settings are reported
+// when they are first seen.
+func (n Basejump) config() config {
+	if n.cfg != nil {
+		return *n.cfg
+	}
+
+	var raw rawConfig
+	err := n.nvim().Eval(rawConfigExpr, &raw)
+	if err != nil {
+		trace(n, "trace: config: reading the configuration failed: %v", err)
+		return defaultConfig()
+	}
+
+	s := n.state
+	key := fmt.Sprint(raw)
+
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+
+	if s.configKey == key && s.configLoaded {
+		return s.config
+	}
+
+	trace(n, "trace: config: parsing the changed configuration")
+	c, errs := parseConfig(raw)
+	for _, err := range errs {
+		logger.Warn("invalid option", "err", err)
+		n.Echom("error: %v", err)
+	}
+
+	s.config, s.configKey, s.configLoaded = c, key, true
+	return c
+}
+
+// withConfig returns a copy of `n` that uses the current configuration until it is done.
+func (n Basejump) withConfig() Basejump {
+	c := n.config()
+	n.cfg = &c
+	return n
+}
+
+func sortedOptionKeys() []string {
+	keys := make([]string, 0, len(configOptions))
+	for k := range configOptions {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// dictValue converts a vim dictionary to a map. A missing dictionary is empty.
+func dictValue(v interface{}) (map[string]interface{}, error) {
+	switch d := v.(type) {
+	case nil:
+		return nil, nil
+	case map[string]interface{}:
+		return d, nil
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(d))
+		for k, v := range d {
+			m[fmt.Sprint(k)] = v
+		}
+		return m, nil
+	}
+	return nil, fmt.Errorf("expected a dictionary but got %v", v)
+}
+
+func stringValue(v interface{}) (string, error) {
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("expected a string but got %v", v)
+	}
+	return s, nil
+}
+
+func stringListValue(v interface{}) ([]string, error) {
+	list, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected a list of strings but got %v", v)
+	}
+	strs := make([]string, 0, len(list))
+	for _, item := range list {
+		s, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected a list of strings but got %v", v)
+		}
+		strs = append(strs, s)
+	}
+	return strs, nil
+}
+
+// intValue converts a vim number to an int, which must be at least `min`.
+func intValue(v interface{}, min int) (i int, err error) {
+	switch n := v.(type) {
+	case int:
+		i = n
+	case int64:
+		i = int(n)
+	case uint64:
+		i = int(n)
+	default:
+		return 0, fmt.Errorf("expected a number but got %v", v)
+	}
+	if i < min {
+		return 0, fmt.Errorf("expected a number of at least %d but got %d", min, i)
+	}
+	return
+}
+
+// boolValue converts a vim number or boolean to a bool. Numbers are true if they are nonzero.
+func boolValue(v interface{}) (bool, error) {
+	switch b := v.(type) {
+	case bool:
+		return b, nil
+	case int:
+		return b != 0, nil
+	case int64:
+		return b != 0, nil
+	case uint64:
+		return b != 0, nil
+	}
+	return false, fmt.Errorf("expected a number or boolean but got %v", v)
+}
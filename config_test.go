@@ -0,0 +1,173 @@
+package main
+
+import (
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseConfig(t *testing.T) {
+	legacy := map[string]interface{}{
+		"basejump_openmode":          "tab",
+		"basejump_open_nonexistent":  int64(0),
+		"basejump_stat_timeout":      int64(500),
+		"basejump_log_level":         "warn",
+		"basejump_resolver_commands": []interface{}{"index-lookup"},
+		// Not options
+		"basejump_event":       map[string]interface{}{"path": "/src/main.go"},
+		"basejump_no_mappings": int64(1),
+	}
+
+	tests := []struct {
+		name   string
+		raw    rawConfig
+		check  func(c config) bool
+		errors []string
+	}{
+		{
+			name: "defaults",
+			check: func(c config) bool {
+				return reflect.DeepEqual(c, defaultConfig())
+			},
+		},
+		{
+			name: "legacy",
+			raw:  rawConfig{Legacy: legacy},
+			check: func(c config) bool {
+				return c.OpenMode == openByTab && !c.OpenNonexistent && c.StatTimeout == 500 && c.LogLevel == slog.LevelWarn &&
+					reflect.DeepEqual(c.ResolverCommands, [][]string{{"sh", "-c", "index-lookup"}})
+			},
+		},
+		{
+			name: "global overrides legacy",
+			raw: rawConfig{Legacy: legacy, Global: map[string]interface{}{
+				"openmode":         "float",
+				"open_nonexistent": true,
+				"log_level":        "debug",
+				"log_file":         "~/basejump.log",
+				"listen":           "default",
+			}},
+			check: func(c config) bool {
+				return c.OpenMode == openByFloat && c.OpenNonexistent && c.StatTimeout == 500 &&
+					c.LogLevel == slog.LevelDebug && c.LogFile == "~/basejump.log" && c.Listen == "default"
+			},
+		},
+		{
+			name: "filetype overrides global",
+			raw: rawConfig{
+				Global: map[string]interface{}{
+					"openmode":  "float",
+					"tagstack":  false,
+					"filetypes": map[string]interface{}{"qf": map[string]interface{}{"openmode": "edit"}, "go": "x"},
+				},
+				Filetype: "qf",
+			},
+			check: func(c config) bool {
+				return c.OpenMode == openByEdit && !c.Tagstack
+			},
+		},
+		{
+			name: "buffer overrides filetype",
+			raw: rawConfig{
+				Global: map[string]interface{}{
+					"filetypes": map[string]interface{}{"qf": map[string]interface{}{"openmode": "edit"}},
+				},
+				Buffer:   map[interface{}]interface{}{"openmode": "vsplit"},
+				Filetype: "qf",
+			},
+			check: func(c config) bool {
+				return c.OpenMode == openByVsplit
+			},
+		},
+		{
+			name: "other filetype",
+			raw: rawConfig{
+				Global: map[string]interface{}{
+					"filetypes": map[string]interface{}{"qf": map[string]interface{}{"openmode": "edit"}},
+				},
+				Filetype: "go",
+			},
+			check: func(c config) bool {
+				return c.OpenMode == openBySplit
+			},
+		},
+		{
+			name: "invalid values are ignored",
+			raw: rawConfig{Legacy: legacy, Global: map[string]interface{}{
+				"openmode":        "sideways",
+				"stat_timeout":    int64(0),
+				"column_unit":     "furlongs",
+				"browsers":        []interface{}{"w3m", int64(1)},
+				"history":         "yes",
+				"log_level":       "verbose",
+				"preview_context": int64(3),
+				"opnemode":        "tab",
+			}},
+			check: func(c config) bool {
+				return c.OpenMode == openByTab && c.StatTimeout == 500 && c.ColumnUnit == ColumnBytes &&
+					len(c.Browsers) == 4 && c.History && c.PreviewContext == 3
+			},
+			errors: []string{
+				"g:basejump.browsers: expected a list of strings",
+				"g:basejump.column_unit: invalid column unit 'furlongs'",
+				"g:basejump.history: expected a number or boolean",
+				"g:basejump.log_level: invalid log level 'verbose'",
+				"g:basejump.openmode: invalid open mode 'sideways'",
+				"g:basejump: unknown option 'opnemode'",
+				"g:basejump.stat_timeout: expected a number of at least 1",
+			},
+		},
+		{
+			name: "invalid legacy value",
+			raw:  rawConfig{Legacy: map[string]interface{}{"basejump_resolver_commands": []interface{}{[]interface{}{}}}},
+			check: func(c config) bool {
+				return c.ResolverCommands == nil
+			},
+			errors: []string{"g:basejump_resolver_commands: [0]: the command is empty"},
+		},
+		{
+			name: "not dictionaries",
+			raw: rawConfig{
+				Global: map[string]interface{}{"filetypes": []interface{}{}},
+				Buffer: "tab",
+			},
+			check: func(c config) bool {
+				return reflect.DeepEqual(c, defaultConfig())
+			},
+			errors: []string{
+				"g:basejump.filetypes: expected a dictionary",
+				"b:basejump: expected a dictionary",
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c, errs := parseConfig(tc.raw)
+			if !tc.check(c) {
+				t.Fatalf("unexpected config %+v", c)
+			}
+			if len(errs) != len(tc.errors) {
+				t.Fatalf("expected %d errors but got %v", len(tc.errors), errs)
+			}
+			for i, expected := range tc.errors {
+				if !strings.HasPrefix(errs[i].Error(), expected) {
+					t.Fatalf("expected error %d to start with '%s' but got '%v'", i, expected, errs[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRawConfigExpr(t *testing.T) {
+	for _, name := range []string{"'basejump_openmode'", "'basejump_resolver_timeout'"} {
+		if !strings.Contains(rawConfigExpr, name) {
+			t.Fatalf("expected the expression to read %s: %s", name, rawConfigExpr)
+		}
+	}
+	// g:basejump_event changes on every jump, and would make the configuration be parsed again
+	if strings.Contains(rawConfigExpr, "basejump_event") || strings.Contains(rawConfigExpr, "^basejump_") {
+		t.Fatalf("expected the expression to read only options: %s", rawConfigExpr)
+	}
+}
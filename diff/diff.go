@@ -2,7 +2,9 @@ package diff
 
 import (
 	"fmt"
+	"os"
 	"strconv"
+	"strings"
 	"unicode"
 )
 
@@ -169,6 +171,43 @@ type LineGetter interface {
 	LineText(line int) (text string, err error)
 }
 
+// LinesGetter is a LineGetter that can also get a range of lines at once. It is used
+// when getting each line is slow, like when it is a round trip to the editor.
+type LinesGetter interface {
+	LineGetter
+	// Lines returns the lines numbered from `first` to `last` inclusive.
+	Lines(first, last int) (lines []string, err error)
+}
+
+// linesChunk is the number of lines fetched at once from a LinesGetter
+const linesChunk = 200
+
+// chunkedLines is a LineGetter that gets lines from a LinesGetter a chunk at a time. Since
+// findDiffProperties walks backwards, the chunk ends at the requested line.
+type chunkedLines struct {
+	LinesGetter
+	first int
+	lines []string
+}
+
+func (c *chunkedLines) LineText(line int) (text string, err error) {
+	if line < c.first || line >= c.first+len(c.lines) {
+		first := line - linesChunk + 1
+		if first < 1 {
+			first = 1
+		}
+		c.lines, err = c.Lines(first, line)
+		if err != nil {
+			return
+		}
+		c.first = first
+		if line >= c.first+len(c.lines) {
+			return "", fmt.Errorf("Invalid line %d", line)
+		}
+	}
+	return c.lines[line-c.first], nil
+}
+
 type PathExists func(path string) bool
 
 func CalcFileAndLine(n LineGetter, pathExists PathExists) (path string, lineNo int, err error) {
@@ -222,6 +261,9 @@ func CalcFileAndLine(n LineGetter, pathExists PathExists) (path string, lineNo i
 }
 
 func findDiffProperties(n LineGetter) (rtok RangeTok, origFile, modFile string, lineCnt int, err error) {
+	if lg, ok := n.(LinesGetter); ok {
+		n = &chunkedLines{LinesGetter: lg}
+	}
 
 	lineno, err := n.CurrentLineNumber()
 	if err != nil {
@@ -294,3 +336,37 @@ func findDiffProperties(n LineGetter) (rtok RangeTok, origFile, modFile string,
 	}
 	return
 }
+
+// FileLineGetter is a LineGetter for the lines of a file, such as a patch file.
+type FileLineGetter struct {
+	current int
+	lines   []string
+}
+
+// NewFileLineGetter reads the file `path` and returns a LineGetter whose current line is
+// `current`. Lines are numbered starting at 1.
+func NewFileLineGetter(path string, current int) (g FileLineGetter, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+
+	g.lines = strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if current < 1 || current > len(g.lines) {
+		err = fmt.Errorf("%s has no line %d", path, current)
+		return
+	}
+	g.current = current
+	return
+}
+
+func (g FileLineGetter) CurrentLineNumber() (num int, err error) {
+	return g.current, nil
+}
+
+func (g FileLineGetter) LineText(line int) (text string, err error) {
+	if line < 1 || line > len(g.lines) {
+		return "", fmt.Errorf("Invalid line %d", line)
+	}
+	return strings.TrimSuffix(g.lines[line-1], "\r"), nil
+}
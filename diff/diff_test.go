@@ -2,8 +2,11 @@ package diff
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestParseRange(t *testing.T) {
@@ -189,3 +192,131 @@ index 77b49f7..498f0fe 100644
 	}
 
 }
+
+func TestFileLineGetter(t *testing.T) {
+	patch := filepath.Join(t.TempDir(), "change.patch")
+	err := os.WriteFile(patch, []byte("--- a/main.go\r\n+++ b/main.go\r\n@@ -10,3 +10,4 @@\r\n context\r\n+added\r\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g, err := NewFileLineGetter(patch, 5)
+	if err != nil {
+		t.Fatalf("Got error: %v", err)
+	}
+
+	path, lineNo, err := CalcFileAndLine(g, mkPathExists("main.go"))
+	if err != nil {
+		t.Fatalf("Got error: %v", err)
+	}
+
+	if path != "main.go" || lineNo != 11 {
+		t.Fatalf("Expected main.go line 11 but got %s line %d", path, lineNo)
+	}
+
+	_, err = NewFileLineGetter(patch, 6)
+	if err == nil {
+		t.Fatalf("Expected an error for a line past the end of the file but got none")
+	}
+}
+
+// countingLinesGetter is a LinesGetter that counts the calls made to it, and takes
+// `latency` to answer each one like a call to an editor would.
+type countingLinesGetter struct {
+	StringLineGetter
+	latency time.Duration
+	calls   int
+}
+
+func (s *countingLinesGetter) LineText(line int) (text string, err error) {
+	s.calls++
+	spin(s.latency)
+	return s.StringLineGetter.LineText(line)
+}
+
+func (s *countingLinesGetter) Lines(first, last int) (lines []string, err error) {
+	s.calls++
+	spin(s.latency)
+	if first < 1 || last > len(s.lines) || first > last {
+		return nil, fmt.Errorf("Invalid lines %d to %d", first, last)
+	}
+	return s.lines[first-1 : last], nil
+}
+
+// spin waits for `d`. Unlike time.Sleep it is accurate for short durations.
+func spin(d time.Duration) {
+	for start := time.Now(); time.Since(start) < d; {
+	}
+}
+
+// lineTextOnly hides the Lines method of a LinesGetter.
+type lineTextOnly struct {
+	LineGetter
+}
+
+// longPatch returns a patch of main.go with a single hunk that adds `added` lines.
+func longPatch(added int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- a/main.go\n+++ b/main.go\n@@ -1,1 +1,%d @@\n context", added+1)
+	for i := 0; i < added; i++ {
+		fmt.Fprintf(&b, "\n+line %d", i)
+	}
+	return b.String()
+}
+
+func TestCalcFileAndLineChunked(t *testing.T) {
+	g := &countingLinesGetter{StringLineGetter: StringLineGetterFromString(longPatch(1000), 1004)}
+
+	path, lineNo, err := CalcFileAndLine(g, mkPathExists("main.go"))
+	if err != nil {
+		t.Fatalf("Got error: %v", err)
+	}
+	if path != "main.go" || lineNo != 1001 {
+		t.Fatalf("Expected main.go line 1001 but got %s line %d", path, lineNo)
+	}
+	if g.calls > 1004/linesChunk+1 {
+		t.Fatalf("Expected the lines to be fetched in chunks but there were %d calls", g.calls)
+	}
+
+	// A line near the start of the buffer can't be fetched with a full chunk
+	g.current = 5
+	path, lineNo, err = CalcFileAndLine(g, mkPathExists("main.go"))
+	if err != nil {
+		t.Fatalf("Got error: %v", err)
+	}
+	if path != "main.go" || lineNo != 2 {
+		t.Fatalf("Expected main.go line 2 but got %s line %d", path, lineNo)
+	}
+}
+
+func BenchmarkCalcFileAndLine(b *testing.B) {
+	patch := longPatch(2000)
+
+	for _, bc := range []struct {
+		name    string
+		chunked bool
+	}{
+		{"LineText", false},
+		{"Lines", true},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			g := &countingLinesGetter{
+				StringLineGetter: StringLineGetterFromString(patch, 2004),
+				// About the time of a round trip to nvim
+				latency: 20 * time.Microsecond,
+			}
+			var lg LineGetter = g
+			if !bc.chunked {
+				lg = lineTextOnly{g}
+			}
+
+			for i := 0; i < b.N; i++ {
+				_, _, err := CalcFileAndLine(lg, mkPathExists("main.go"))
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(g.calls)/float64(b.N), "calls/op")
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jeffwilliams/basejump/jump"
+)
+
+// Basejump implements jump.Editor for the nvim instance returned by nvim().
+var _ jump.Editor = Basejump{}
+
+func (n Basejump) CurrentTab() (tab int, err error) {
+	err = n.nvim().Call("tabpagenr", &tab)
+	return
+}
+
+// windowInfo describes a window. It is decoded from the result of windowInfoExpr.
+type windowInfo struct {
+	Tab  int    `msgpack:"tab"`
+	Win  int    `msgpack:"win"`
+	Name string `msgpack:"name"`
+	Cwd  string `msgpack:"cwd"`
+	// Pid is the pid of the process running in the window if it is a terminal, otherwise 0
+	Pid int `msgpack:"pid"`
+}
+
+// windowInfoExpr evaluates to a windowInfo for each window in the list of getwininfo()
+// results `%s`, so that every window is described in one round trip.
+const windowInfoExpr = `map(%s, {_, w -> {'tab': w.tabnr, 'win': w.winnr, 'name': nvim_buf_get_name(w.bufnr),` +
+	` 'cwd': getcwd(w.winnr, w.tabnr), 'pid': getbufvar(w.bufnr, 'terminal_job_pid', 0)}})`
+
+func (n Basejump) Windows(tab int) (wins []jump.Window, err error) {
+	list := "getwininfo()"
+	if tab != 0 {
+		list = fmt.Sprintf("filter(getwininfo(), {_, w -> w.tabnr == %d})", tab)
+	}
+
+	var infos []windowInfo
+	err = n.nvim().Eval(fmt.Sprintf(windowInfoExpr, list), &infos)
+	if err != nil {
+		return
+	}
+
+	for _, info := range infos {
+		win := jump.Window{Tab: info.Tab, Number: info.Win, Name: info.Name, Cwd: info.Cwd}
+		if info.Pid != 0 {
+			// The cwd of a terminal is the cwd of the process running in it
+			if cwd, perr := n.pidCwd(info.Pid); perr == nil && cwd != "" {
+				win.Cwd = cwd
+			}
+		}
+		wins = append(wins, win)
+	}
+	return
+}
+
+// bufferInfo describes a buffer. It is decoded from a getbufinfo() result.
+type bufferInfo struct {
+	Nr     int    `msgpack:"bufnr"`
+	Name   string `msgpack:"name"`
+	Loaded int    `msgpack:"loaded"`
+}
+
+func (n Basejump) Buffers() (bufs []jump.Buffer, err error) {
+	// All the buffers are described in one round trip
+	var infos []bufferInfo
+	err = n.nvim().Eval(`map(getbufinfo(), {_, b -> {'bufnr': b.bufnr, 'name': b.name, 'loaded': b.loaded}})`, &infos)
+	if err != nil {
+		return
+	}
+
+	for _, info := range infos {
+		bufs = append(bufs, jump.Buffer{Number: info.Nr, Name: info.Name, Loaded: info.Loaded != 0})
+	}
+	return
+}
+
+func (n Basejump) Cwd(window int) (cwd string, err error) {
+	// The window's cwd, and the pid of the process running in it if it is a terminal,
+	// are fetched in one round trip.
+	expr := `{'pid': getbufvar('%', 'terminal_job_pid', 0), 'cwd': getcwd()}`
+	if window != -1 {
+		expr = fmt.Sprintf(`{'pid': getbufvar(winbufnr(%d), 'terminal_job_pid', 0), 'cwd': getcwd(%d)}`, window, window)
+	}
+
+	var info windowInfo
+	err = n.nvim().Eval(expr, &info)
+	if err != nil {
+		return
+	}
+
+	// If the window is a terminal window, then we need
+	// to get the cwd in a special way: it is the cwd of the
+	// process running in the terminal, not of the window.
+	if info.Pid != 0 {
+		cwd, err = n.pidCwd(info.Pid)
+		if err != nil || cwd != "" {
+			return
+		}
+	}
+	return info.Cwd, nil
+}
+
+func (n Basejump) pidCwd(pid int) (string, error) {
+	return os.Readlink(fmt.Sprintf("/proc/%d/cwd", pid))
+}
+
+func (n Basejump) Command(cmd string) error {
+	return n.nvim().Command(cmd)
+}
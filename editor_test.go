@@ -0,0 +1,126 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/jeffwilliams/basejump/jump"
+	"github.com/neovim/go-client/nvim"
+)
+
+// windowsPerCall lists the windows the way Windows did before it used windowInfoExpr,
+// with several calls to nvim for each window.
+func windowsPerCall(nv *nvim.Nvim) (wins []jump.Window, err error) {
+	tabs, err := nv.Tabpages()
+	if err != nil {
+		return
+	}
+
+	for _, tp := range tabs {
+		var tabNr int
+		tabNr, err = nv.TabpageNumber(tp)
+		if err != nil {
+			return
+		}
+		var tabWins []nvim.Window
+		tabWins, err = nv.TabpageWindows(tp)
+		if err != nil {
+			return
+		}
+
+		for _, w := range tabWins {
+			win := jump.Window{Tab: tabNr}
+
+			var buf nvim.Buffer
+			buf, err = nv.WindowBuffer(w)
+			if err != nil {
+				return
+			}
+			win.Name, err = nv.BufferName(buf)
+			if err != nil {
+				return
+			}
+			win.Number, err = nv.WindowNumber(w)
+			if err != nil {
+				return
+			}
+
+			var pid int
+			err = nv.Call("getbufvar", &pid, int(buf), "terminal_job_pid", 0)
+			if err != nil {
+				return
+			}
+			err = nv.Call("getcwd", &win.Cwd, win.Number, tabNr)
+			if err != nil {
+				return
+			}
+			wins = append(wins, win)
+		}
+	}
+	return
+}
+
+// buffersPerCall lists the buffers the way Buffers did before it used getbufinfo(), with
+// two calls to nvim for each buffer.
+func buffersPerCall(nv *nvim.Nvim) (bufs []jump.Buffer, err error) {
+	all, err := nv.Buffers()
+	if err != nil {
+		return
+	}
+
+	for _, b := range all {
+		buf := jump.Buffer{Number: int(b)}
+		buf.Name, err = nv.BufferName(b)
+		if err != nil {
+			return
+		}
+		buf.Loaded, err = nv.IsBufferLoaded(b)
+		if err != nil {
+			return
+		}
+		bufs = append(bufs, buf)
+	}
+	return
+}
+
+// BenchmarkWindows compares listing the windows and buffers of an nvim with 4 tabs of 4
+// windows each, with calls for each window or buffer and in one call.
+func BenchmarkWindows(b *testing.B) {
+	n := embeddedBasejump(b)
+	nv := n.nvim()
+
+	for i := 0; i < 4; i++ {
+		if i > 0 {
+			if err := nv.Command("tabnew"); err != nil {
+				b.Fatal(err)
+			}
+		}
+		for j := 0; j < 3; j++ {
+			if err := nv.Command("new"); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+
+	benchmarks := []struct {
+		name string
+		list func() (int, error)
+	}{
+		{"WindowsPerCall", func() (int, error) { w, err := windowsPerCall(nv); return len(w), err }},
+		{"Windows", func() (int, error) { w, err := n.Windows(0); return len(w), err }},
+		{"BuffersPerCall", func() (int, error) { bufs, err := buffersPerCall(nv); return len(bufs), err }},
+		{"Buffers", func() (int, error) { bufs, err := n.Buffers(); return len(bufs), err }},
+	}
+	for _, bc := range benchmarks {
+		b.Run(bc.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				count, err := bc.list()
+				if err != nil {
+					b.Fatal(err)
+				}
+				if count < 16 {
+					b.Fatalf("expected at least 16 windows or buffers but got %d", count)
+				}
+			}
+		})
+	}
+}
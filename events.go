@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// errJumpCanceled is returned when a BasejumpPre autocommand cancels a jump.
+var errJumpCanceled = errors.New("jump canceled by a BasejumpPre autocommand")
+
+// jumpEvent describes a jump to the User BasejumpPre and BasejumpPost autocommands. It
+// is stored in g:basejump_event while they run, and removed afterwards.
+type jumpEvent struct {
+	Path   string `msgpack:"path"`
+	Line   int    `msgpack:"line"`
+	Col    int    `msgpack:"col"`
+	Method string `msgpack:"method"`
+	// Cancel may be set by BasejumpPre to cancel the jump. Vim may set it to a number or a boolean.
+	Cancel interface{} `msgpack:"cancel"`
+	// WinID and Bufnr are the window and buffer the jump ended in. They are only set for BasejumpPost.
+	WinID int `msgpack:"winid"`
+	Bufnr int `msgpack:"bufnr"`
+}
+
+// fireEvent stores `ev` in g:basejump_event and runs the User autocommands for `name`, if
+// there are any. It returns the event as the autocommands left it, and removes
+// g:basejump_event.
+func (n Basejump) fireEvent(name string, ev jumpEvent) (result jumpEvent, err error) {
+	nv := n.nvim()
+	result = ev
+
+	var exists int
+	err = nv.Call("exists", &exists, "#User#"+name)
+	if err != nil || exists == 0 {
+		return
+	}
+
+	err = nv.SetVar("basejump_event", ev)
+	if err != nil {
+		return
+	}
+	defer func() {
+		uerr := nv.Command("unlet! g:basejump_event")
+		if err == nil {
+			err = uerr
+		}
+	}()
+
+	trace(n, "trace: fireEvent: User %s for %s", name, ev.Path)
+	err = nv.Command(fmt.Sprintf("doautocmd <nomodeline> User %s", name))
+	if err != nil {
+		return
+	}
+
+	err = nv.Var("basejump_event", &result)
+	return
+}
+
+// canceled returns true if BasejumpPre set the cancel key of the event.
+func (ev jumpEvent) canceled() bool {
+	switch v := ev.Cancel.(type) {
+	case bool:
+		return v
+	case int64:
+		return v != 0
+	case uint64:
+		return v != 0
+	case int:
+		return v != 0
+	}
+	return false
+}
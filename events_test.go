@@ -0,0 +1,79 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPostEventInTargetWindow(t *testing.T) {
+	n := embeddedBasejump(t)
+	nv := n.nvim()
+
+	path := filepath.Join(t.TempDir(), "a.txt")
+	if err := os.WriteFile(path, []byte("x\ny\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A preview returns to the window the jump started from after the jump
+	err := nv.Command("autocmd User BasejumpPost let g:post = [win_getid(), g:basejump_event.winid]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = n.openPathAtLineCol(path, 2, 1, openByPreview)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var post []int
+	err = nv.Var("post", &post)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(post) != 2 || post[0] != post[1] {
+		t.Fatalf("expected BasejumpPost to run in the target window but got %v", post)
+	}
+
+	var exists int
+	err = nv.Eval("exists('g:basejump_event')", &exists)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists != 0 {
+		t.Fatalf("g:basejump_event was not removed")
+	}
+}
+
+func TestPreEventPathResolved(t *testing.T) {
+	n := embeddedBasejump(t)
+	nv := n.nvim()
+
+	dir := t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("x\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := nv.Command("cd " + dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = nv.Command("autocmd User BasejumpPre let g:basejump_event.path = 'b.txt'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = n.openPathAtLineCol(filepath.Join(dir, "a.txt"), 1, 1, openByEdit)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var opened string
+	err = nv.Call("expand", &opened, "%:p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(dir, "b.txt"); opened != want {
+		t.Fatalf("expected %s to be opened but got %s", want, opened)
+	}
+}
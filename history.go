@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/jeffwilliams/basejump/history"
+)
+
+// historyFile returns the file that jumps are recorded in. If g:basejump_history
+// is zero `enabled` is false.
+func (n Basejump) historyFile() (h history.File, enabled bool, err error) {
+	nv := n.nvim()
+
+	c := n.config()
+	if !c.History {
+		return
+	}
+
+	var dataDir string
+	err = nv.Call("stdpath", &dataDir, "data")
+	if err != nil {
+		return
+	}
+
+	h.Path = filepath.Join(dataDir, "basejump", "history.jsonl")
+	h.MaxEntries = c.HistorySize
+	enabled = true
+	return
+}
+
+// sourceName returns the name of the current buffer, which is recorded in
+// the history as the source of a jump.
+func (n Basejump) sourceName() (name string, err error) {
+	err = n.nvim().Call("bufname", &name, "%")
+	return
+}
+
+// recordJump adds a jump from the text `text` in the buffer named `source` to the
+// absolute path `path` to the history. Failures are only traced, since they shouldn't prevent jumps.
+func (n Basejump) recordJump(text, source, path string, line, col int) {
+	h, enabled, err := n.historyFile()
+	if err != nil || !enabled {
+		return
+	}
+
+	err = h.Append(history.Entry{
+		Time:   time.Now(),
+		Text:   text,
+		Path:   path,
+		Line:   line,
+		Col:    col,
+		Source: source,
+	})
+	if err != nil {
+		trace(n, "trace: recordJump: appending to %s failed: %v", h.Path, err)
+	}
+}
+
+// historyLimit is the number of jumps shown by ShowHistory.
+const historyLimit = 200
+
+// ShowHistory lists the most recent jumps in a new window. Pressing Enter on a jump
+// repeats it using the open mode `method`.
+func (n Basejump) ShowHistory(method string) error {
+	nv := n.nvim()
+
+	h, enabled, err := n.historyFile()
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		return fmt.Errorf("jump history is disabled. Set g:basejump_history to enable it")
+	}
+
+	entries, err := h.Recent(historyLimit)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("no jumps in the history")
+	}
+
+	lines := make([]string, len(entries))
+	for i, e := range entries {
+		lines[i] = formatHistoryEntry(e)
+	}
+
+	reused, err := n.gotoHistoryWindow()
+	if err != nil {
+		return err
+	}
+
+	err = nv.Call("setline", nil, 1, lines)
+	if err != nil {
+		return err
+	}
+
+	cmds := []string{"setlocal buftype=nofile bufhidden=wipe noswapfile nomodifiable nowrap"}
+	if !reused {
+		cmds = append(cmds, "file "+historyBufferName)
+	}
+	cmds = append(cmds,
+		"nnoremap <buffer> <silent> <CR> <Cmd>call BasejumpHistoryOpen()<CR>",
+		"nnoremap <buffer> <silent> q <Cmd>close<CR>",
+	)
+	for _, cmd := range cmds {
+		err = nv.Command(cmd)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = nv.Command(fmt.Sprintf("resize %d", limit(len(lines), 1, 15)))
+	if err != nil {
+		return err
+	}
+
+	infos := make([]savedJump, len(entries))
+	for i, e := range entries {
+		infos[i] = savedJump{e.Path, e.Line, e.Col, method}
+	}
+	return nv.Call("setbufvar", nil, "%", "basejump_history", infos)
+}
+
+// historyBufferName is the name of the buffer ShowHistory lists the jumps in.
+const historyBufferName = "[basejump history]"
+
+// gotoHistoryWindow makes a window for ShowHistory to list the jumps in the current
+// window, and empties it. If the history is already shown its window is reused and
+// `reused` is true, otherwise a new window is opened at the bottom.
+func (n Basejump) gotoHistoryWindow() (reused bool, err error) {
+	nv := n.nvim()
+
+	var existing struct {
+		Bufnr   int   `msgpack:"bufnr"`
+		Windows []int `msgpack:"windows"`
+	}
+	err = nv.Eval(`get(filter(getbufinfo(), {_, b -> b.name =~# '\[basejump history\]$'}), 0, {'bufnr': 0, 'windows': []})`, &existing)
+	if err != nil {
+		return
+	}
+
+	if len(existing.Windows) > 0 {
+		err = nv.Call("win_gotoid", nil, existing.Windows[0])
+		if err != nil {
+			return
+		}
+		return true, nv.Command("setlocal modifiable | silent %delete _")
+	}
+
+	if existing.Bufnr != 0 {
+		// A hidden history buffer would stop the new one from taking its name
+		err = nv.Command(fmt.Sprintf("bwipeout %d", existing.Bufnr))
+		if err != nil {
+			return
+		}
+	}
+	err = nv.Command("botright new")
+	return
+}
+
+// HistoryOpen repeats the jump under the cursor in the window opened by ShowHistory.
+func (n Basejump) HistoryOpen() error {
+	nv := n.nvim()
+
+	var infos []savedJump
+	err := nv.Eval("b:basejump_history", &infos)
+	if err != nil {
+		return fmt.Errorf("not in the basejump history window")
+	}
+
+	var line int
+	err = nv.Call("line", &line, ".")
+	if err != nil {
+		return err
+	}
+	if line < 1 || line > len(infos) {
+		return fmt.Errorf("no jump on this line")
+	}
+	info := infos[line-1]
+
+	err = nv.Command("close")
+	if err != nil {
+		return err
+	}
+
+	err = n.OpenPathAtLineCol(info.Path, info.Line, info.Col, info.Method)
+	if err != nil {
+		return err
+	}
+
+	n.recordJump(info.Path, historyBufferName, info.Path, info.Line, info.Col)
+	return nil
+}
+
+// formatHistoryEntry formats `e` as a line in the window opened by ShowHistory.
+func formatHistoryEntry(e history.Entry) string {
+	target := e.Path
+	if e.Line > 0 {
+		target = fmt.Sprintf("%s:%d", target, e.Line)
+		if e.Col > 0 {
+			target = fmt.Sprintf("%s:%d", target, e.Col)
+		}
+	}
+
+	s := fmt.Sprintf("%s  %s", e.Time.Local().Format("2006-01-02 15:04:05"), target)
+	if e.Source != "" {
+		s = fmt.Sprintf("%s  (from %s)", s, e.Source)
+	}
+	return s
+}
@@ -0,0 +1,144 @@
+// Package history stores the jumps made by basejump in a file, so that they
+// can be browsed and repeated later.
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Entry is a single jump.
+type Entry struct {
+	// Time is when the jump was made
+	Time time.Time `json:"time"`
+	// Text is the text the jump was made from
+	Text string `json:"text,omitempty"`
+	// Path, Line and Col are the target of the jump
+	Path string `json:"path"`
+	Line int    `json:"line,omitempty"`
+	Col  int    `json:"col,omitempty"`
+	// Source is the name of the buffer the jump was made from
+	Source string `json:"source,omitempty"`
+}
+
+// File is a history stored in a file, with one JSON encoded entry per line.
+type File struct {
+	Path string
+	// MaxEntries is the number of entries that are kept. When the file grows
+	// larger than twice this, the oldest entries are removed. If it is 0 all
+	// entries are kept.
+	MaxEntries int
+}
+
+// Append adds the entry `e` to the end of the history.
+func (h File) Append(e Entry) (err error) {
+	err = os.MkdirAll(filepath.Dir(h.Path), 0700)
+	if err != nil {
+		return
+	}
+
+	f, err := os.OpenFile(h.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+
+	b, err := json.Marshal(e)
+	if err != nil {
+		f.Close()
+		return
+	}
+	b = append(b, '\n')
+	_, err = f.Write(b)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return
+	}
+
+	return h.trim()
+}
+
+// Recent returns the last `n` entries in the history, most recent first. If `n`
+// is 0 all entries are returned. A missing history file is an empty history.
+// Lines that can't be parsed are skipped.
+func (h File) Recent(n int) (entries []Entry, err error) {
+	all, err := h.load()
+	if err != nil {
+		return
+	}
+
+	for i := len(all) - 1; i >= 0; i-- {
+		if n > 0 && len(entries) == n {
+			break
+		}
+		entries = append(entries, all[i])
+	}
+	return
+}
+
+func (h File) load() (entries []Entry, err error) {
+	f, err := os.Open(h.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		var e Entry
+		if json.Unmarshal(scanner.Bytes(), &e) != nil {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	err = scanner.Err()
+	return
+}
+
+// trim rewrites the history with only the last MaxEntries entries if it has
+// grown to more than twice that size.
+func (h File) trim() (err error) {
+	if h.MaxEntries <= 0 {
+		return
+	}
+
+	entries, err := h.load()
+	if err != nil || len(entries) <= 2*h.MaxEntries {
+		return
+	}
+	entries = entries[len(entries)-h.MaxEntries:]
+
+	tmp := h.Path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, e := range entries {
+		if err = enc.Encode(e); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return
+	}
+
+	return os.Rename(tmp, h.Path)
+}
@@ -0,0 +1,86 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAppendAndRecent(t *testing.T) {
+	h := File{Path: filepath.Join(t.TempDir(), "sub", "history.jsonl")}
+
+	entries, err := h.Recent(10)
+	if err != nil {
+		t.Fatalf("Recent on a missing file returned error: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("Expected no entries but got %d", len(entries))
+	}
+
+	now := time.Now().Truncate(time.Second)
+	for i, p := range []string{"/a.go", "/b.go", "/c.go"} {
+		err = h.Append(Entry{Time: now, Text: p, Path: p, Line: i + 1, Source: "log"})
+		if err != nil {
+			t.Fatalf("Append returned error: %v", err)
+		}
+	}
+
+	entries, err = h.Recent(2)
+	if err != nil {
+		t.Fatalf("Recent returned error: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries but got %d", len(entries))
+	}
+	if entries[0].Path != "/c.go" || entries[0].Line != 3 || entries[1].Path != "/b.go" {
+		t.Fatalf("Entries are wrong or in the wrong order: %v", entries)
+	}
+	if !entries[0].Time.Equal(now) || entries[0].Source != "log" {
+		t.Fatalf("Entry fields were not preserved: %v", entries[0])
+	}
+
+	entries, err = h.Recent(0)
+	if err != nil {
+		t.Fatalf("Recent returned error: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 entries but got %d", len(entries))
+	}
+}
+
+func TestSkipsBadLines(t *testing.T) {
+	h := File{Path: filepath.Join(t.TempDir(), "history.jsonl")}
+	err := os.WriteFile(h.Path, []byte("{\"path\":\"/a.go\"}\nnot json\n{\"path\":\"/b.go\"}\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := h.Recent(0)
+	if err != nil {
+		t.Fatalf("Recent returned error: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Path != "/b.go" || entries[1].Path != "/a.go" {
+		t.Fatalf("Unexpected entries: %v", entries)
+	}
+}
+
+func TestTrim(t *testing.T) {
+	h := File{Path: filepath.Join(t.TempDir(), "history.jsonl"), MaxEntries: 2}
+
+	for i := 1; i <= 5; i++ {
+		err := h.Append(Entry{Path: "/f.go", Line: i})
+		if err != nil {
+			t.Fatalf("Append returned error: %v", err)
+		}
+	}
+
+	entries, err := h.load()
+	if err != nil {
+		t.Fatalf("load returned error: %v", err)
+	}
+	// Trimmed to 2 when the 5th entry was appended
+	if len(entries) != 2 || entries[0].Line != 4 || entries[1].Line != 5 {
+		t.Fatalf("Unexpected entries after trimming: %v", entries)
+	}
+}
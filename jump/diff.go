@@ -0,0 +1,30 @@
+package jump
+
+import (
+	"github.com/jeffwilliams/basejump/diff"
+)
+
+// LineFromDiff returns the file and line in the modified file that the current line
+// of a unified diff refers to. The paths in the diff are relative to the working
+// directory of the current window. They are checked with `exists`, or with PathExists
+// if it is nil.
+func LineFromDiff(e Editor, exists diff.PathExists) (path string, lineNo int, err error) {
+	if exists == nil {
+		exists = PathExists
+	}
+
+	cwd, err := e.Cwd(-1)
+	if err != nil {
+		return
+	}
+
+	path, lineNo, err = diff.CalcFileAndLine(e, func(path string) bool {
+		return exists(AbsPathRelDir(path, cwd))
+	})
+	if err != nil {
+		return
+	}
+
+	path = AbsPathRelDir(path, cwd)
+	return
+}
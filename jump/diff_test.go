@@ -0,0 +1,62 @@
+package jump
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLineFromDiff(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	patch := `diff --git a/main.go b/main.go
+--- a/main.go
++++ b/main.go
+@@ -10,4 +10,5 @@ func main() {
+ 	a := 1
+-	b := 2
++	b := 3
++	c := 4
+ 	return`
+
+	tests := []struct {
+		line   int
+		path   string
+		lineNo int
+		err    bool
+	}{
+		{5, filepath.Join(dir, "main.go"), 10, false},
+		{7, filepath.Join(dir, "main.go"), 12, false},
+		{9, filepath.Join(dir, "main.go"), 14, false},
+		{4, "", 0, true},
+	}
+	for _, tc := range tests {
+		e := &fakeEditor{
+			tabs:    [][]fakeWindow{{{buf: 1}}},
+			curTab:  1,
+			curWin:  1,
+			buffers: []Buffer{{Number: 1, Name: "change.patch", Loaded: true}},
+			cwd:     dir,
+			lines:   strings.Split(patch, "\n"),
+			curLine: tc.line,
+		}
+
+		path, lineNo, err := LineFromDiff(e, nil)
+		if tc.err {
+			if err == nil {
+				t.Fatalf("line %d: expected an error but got %s %d", tc.line, path, lineNo)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("line %d: got error: %v", tc.line, err)
+		}
+		if path != tc.path || lineNo != tc.lineNo {
+			t.Fatalf("line %d: expected %s %d but got %s %d", tc.line, tc.path, tc.lineNo, path, lineNo)
+		}
+	}
+}
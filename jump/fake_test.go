@@ -0,0 +1,91 @@
+package jump
+
+import (
+	"fmt"
+)
+
+// fakeEditor is an in-memory Editor. Commands are recorded rather than run.
+type fakeEditor struct {
+	// tabs holds the windows of each tab page
+	tabs   [][]fakeWindow
+	curTab int
+	// curWin is the number of the current window in the current tab
+	curWin  int
+	buffers []Buffer
+	// cwd is the global working directory, used by windows that don't have their own
+	cwd string
+
+	lines   []string
+	curLine int
+
+	commands []string
+}
+
+type fakeWindow struct {
+	buf int
+	// cwd is the window's local working directory, if it has one
+	cwd string
+}
+
+func (e *fakeEditor) CurrentLineNumber() (num int, err error) {
+	return e.curLine, nil
+}
+
+func (e *fakeEditor) LineText(line int) (text string, err error) {
+	if line < 1 || line > len(e.lines) {
+		return "", fmt.Errorf("Invalid line %d", line)
+	}
+	return e.lines[line-1], nil
+}
+
+func (e *fakeEditor) CurrentTab() (tab int, err error) {
+	return e.curTab, nil
+}
+
+func (e *fakeEditor) Windows(tab int) (wins []Window, err error) {
+	for i, tabWins := range e.tabs {
+		if tab != 0 && tab != i+1 {
+			continue
+		}
+		for j, w := range tabWins {
+			wins = append(wins, Window{Tab: i + 1, Number: j + 1, Name: e.bufName(w.buf), Cwd: e.winCwd(w)})
+		}
+	}
+	return
+}
+
+func (e *fakeEditor) Buffers() (bufs []Buffer, err error) {
+	return e.buffers, nil
+}
+
+func (e *fakeEditor) Cwd(window int) (cwd string, err error) {
+	if window == -1 {
+		window = e.curWin
+	}
+	wins := e.tabs[e.curTab-1]
+	if window < 1 || window > len(wins) {
+		return "", fmt.Errorf("no window %d", window)
+	}
+	return e.winCwd(wins[window-1]), nil
+}
+
+func (e *fakeEditor) Command(cmd string) error {
+	e.commands = append(e.commands, cmd)
+	return nil
+}
+
+func (e *fakeEditor) bufName(bufnr int) string {
+	for _, b := range e.buffers {
+		if b.Number == bufnr {
+			return b.Name
+		}
+	}
+	return ""
+}
+
+func (e *fakeEditor) winCwd(w fakeWindow) string {
+	if w.cwd != "" {
+		return w.cwd
+	}
+	return e.cwd
+}
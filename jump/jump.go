@@ -0,0 +1,50 @@
+// Package jump resolves text like main.go:12:5 into the file, line and column it
+// refers to, and decides which window of an editor a file is opened in. It talks to
+// the editor only through the Editor interface, so it can be used with editors other
+// than nvim, and tested without one.
+package jump
+
+import (
+	"github.com/jeffwilliams/basejump/diff"
+)
+
+// Window is a window in an editor.
+type Window struct {
+	// Tab is the number of the tab page the window is in, starting at 1
+	Tab int
+	// Number is the number of the window in its tab page, starting at 1
+	Number int
+	// Name is the name of the buffer shown in the window, which is usually the path of a file
+	Name string
+	// Cwd is the working directory of the window, which relative buffer names are relative to
+	Cwd string
+}
+
+// Buffer is a buffer in an editor.
+type Buffer struct {
+	// Number identifies the buffer
+	Number int
+	// Name is the name of the buffer, which is usually the path of a file
+	Name string
+	// Loaded is false if the buffer's file has not been read
+	Loaded bool
+}
+
+// Editor is what this package needs from an editor. Window numbers are those of the
+// windows in the current tab page.
+type Editor interface {
+	diff.LineGetter
+	// CurrentTab returns the number of the current tab page.
+	CurrentTab() (tab int, err error)
+	// Windows returns the windows in the tab page numbered `tab`, or the windows in
+	// every tab page if `tab` is 0.
+	Windows(tab int) (wins []Window, err error)
+	// Buffers returns every buffer, including hidden and unlisted buffers.
+	Buffers() (bufs []Buffer, err error)
+	// Cwd returns the working directory of the window `window`, or of the current window
+	// if `window` is -1. For a terminal this is the working directory of the process
+	// running in it.
+	Cwd(window int) (cwd string, err error)
+	// Command runs an ex command, like :split file.go.
+	Command(cmd string) error
+}
@@ -0,0 +1,256 @@
+package jump
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var pathRegex = regexp.MustCompile(`^([^:]+)(?::(\d+))?(?::(\d+))?`)
+
+// ParsePath parses `text` into a filesystem path, line, and column. The `text`
+// parameter must have one of the formats:
+//
+//	<path>               (for example file.go, or /bin/bash)
+//	<path>:<line>        (for example file.go:100)
+//	<path>:<line>:<col>  (for example file.go:100:20)
+//
+// If the parsed path is not absolute it is made absolute by prepending the
+// cwd of the window `window`, or of the current window if `window` is -1.
+//
+// If line and or col is missing, they are set to 0. The column is returned as written
+// in `text`, in whatever unit the tool that produced it counts columns.
+func ParsePath(e Editor, text string, window int) (fpath string, line, col int, err error) {
+	fpath, line, col, err = ParsePathText(text)
+	if err != nil {
+		return
+	}
+
+	fpath, err = AbsPath(e, fpath, window)
+	return
+}
+
+// ParsePathText parses `text` into a path, line and column like ParsePath does, but
+// leaves the path as it is written in `text`.
+func ParsePathText(text string) (fpath string, line, col int, err error) {
+	text = strings.TrimSpace(text)
+
+	match := pathRegex.FindStringSubmatch(text)
+	if match == nil || len(match) < 2 {
+		err = fmt.Errorf("doesn't seem to be a valid path")
+		return
+	}
+	fpath = match[1]
+	if len(match) > 2 && match[2] != "" {
+		line, err = strconv.Atoi(match[2])
+		if err != nil {
+			return
+		}
+	}
+	if len(match) > 3 && match[3] != "" {
+		col, err = strconv.Atoi(match[3])
+	}
+	return
+}
+
+// AbsPath makes the path `fpath` absolute if it is not by prepending
+// the working directory of the window `window`, or of the current window if
+// `window` is -1.
+func AbsPath(e Editor, fpath string, window int) (result string, err error) {
+	if path.IsAbs(fpath) {
+		return fpath, nil
+	}
+
+	cwd, err := e.Cwd(window)
+	if err != nil {
+		return
+	}
+	return AbsPathRelDir(fpath, cwd), nil
+}
+
+// AbsPathRelDir makes the path `fpath` absolute if it is not by prepending
+// the directory `dir`.
+func AbsPathRelDir(fpath, dir string) string {
+	if path.IsAbs(fpath) {
+		return fpath
+	}
+	return dir + "/" + fpath
+}
+
+// Target is what a path or URL in text refers to.
+type Target struct {
+	// Path is the absolute path of a file or directory
+	Path string
+	// Line and Col are the position within the file, or 0 if not specified. The
+	// column is counted in whatever unit the text uses.
+	Line, Col int
+	// URL is set instead of Path if the text is a remote URL
+	URL *url.URL
+}
+
+// How a Target was resolved from text, as returned by ParseTarget.
+const (
+	ResolvedURL          = "url"
+	ResolvedFileURL      = "file url"
+	ResolvedAbsolutePath = "absolute path"
+	ResolvedRelativePath = "relative path"
+)
+
+// ParseTarget determines what the path or URL `text` refers to without checking
+// that it exists, and describes how it was resolved in `how`. Relative paths are made
+// absolute using `abs`.
+func ParseTarget(text string, abs func(fpath string) (string, error)) (t Target, how string, err error) {
+	// First, check for a URL
+	url, err := url.Parse(text)
+	if err == nil {
+		if url.Scheme == "file" {
+			t.Path = url.Path
+			how = ResolvedFileURL
+			return
+		} else if url.Scheme == "http" || url.Scheme == "https" {
+			t.URL = url
+			how = ResolvedURL
+			return
+		}
+	}
+
+	t.Path, t.Line, t.Col, err = ParsePathText(text)
+	if err != nil {
+		return
+	}
+
+	how = ResolvedAbsolutePath
+	if !path.IsAbs(t.Path) {
+		how = ResolvedRelativePath
+	}
+	t.Path, err = abs(t.Path)
+	return
+}
+
+// SamePath returns true if the paths `a` and `b` refer to the same file. Files that exist
+// are compared by identity (device and inode), so symlinks and different spellings of
+// the same path match. Otherwise the paths are compared after resolving symlinks. It
+// gives up when `ctx` is done, like PathExistsContext.
+func SamePath(ctx context.Context, a, b string) (same bool, err error) {
+	if path.Clean(a) == path.Clean(b) {
+		return true, nil
+	}
+
+	done := make(chan bool, 1)
+	go func(stat func(string) (os.FileInfo, error)) {
+		done <- samePath(stat, a, b)
+	}(stat)
+
+	select {
+	case same = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	return
+}
+
+func samePath(stat func(string) (os.FileInfo, error), a, b string) bool {
+	ai, aerr := stat(a)
+	bi, berr := stat(b)
+	if aerr == nil && berr == nil {
+		return os.SameFile(ai, bi)
+	}
+	if aerr == nil || berr == nil {
+		return false
+	}
+
+	return canonicalPath(a) == canonicalPath(b)
+}
+
+// canonicalPath resolves the symlinks in the longest leading part of `fpath`
+// that exists, and cleans the result.
+func canonicalPath(fpath string) string {
+	fpath = filepath.Clean(fpath)
+	rest := ""
+	for dir := fpath; ; dir = filepath.Dir(dir) {
+		if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+			return filepath.Join(resolved, rest)
+		}
+		if dir == filepath.Dir(dir) {
+			return fpath
+		}
+		rest = filepath.Join(filepath.Base(dir), rest)
+	}
+}
+
+// stat is os.Stat. Tests replace it to simulate a hung filesystem.
+var stat = os.Stat
+
+// PathExists returns true if `path` exists.
+func PathExists(path string) bool {
+	return statExists(stat, path)
+}
+
+func statExists(stat func(string) (os.FileInfo, error), path string) bool {
+	_, err := stat(path)
+	return !os.IsNotExist(err)
+}
+
+// PathExistsContext is like PathExists, but gives up when `ctx` is done so that a path on
+// a hung network filesystem can't block the caller. The check carries on in the background
+// until the filesystem responds.
+func PathExistsContext(ctx context.Context, path string) (exists bool, err error) {
+	done := make(chan bool, 1)
+	go func(stat func(string) (os.FileInfo, error)) {
+		done <- statExists(stat, path)
+	}(stat)
+
+	select {
+	case exists = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	return
+}
+
+// isDir returns true if `path` is a directory. It gives up when `ctx` is done.
+func isDir(ctx context.Context, path string) (dir bool, err error) {
+	done := make(chan bool, 1)
+	go func(stat func(string) (os.FileInfo, error)) {
+		fi, err := stat(path)
+		done <- err == nil && fi.IsDir()
+	}(stat)
+
+	select {
+	case dir = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	return
+}
+
+// FirstExisting checks whether each of `paths` exists in parallel, and returns the index
+// of the first one that exists, or -1 if none do. It gives up when `ctx` is done, unless
+// the answer is already known.
+func FirstExisting(ctx context.Context, paths []string) (index int, err error) {
+	results := make([]chan bool, len(paths))
+	for i, path := range paths {
+		results[i] = make(chan bool, 1)
+		go func(stat func(string) (os.FileInfo, error), path string, result chan<- bool) {
+			result <- statExists(stat, path)
+		}(stat, path, results[i])
+	}
+
+	for i, result := range results {
+		select {
+		case exists := <-result:
+			if exists {
+				return i, nil
+			}
+		case <-ctx.Done():
+			return -1, ctx.Err()
+		}
+	}
+	return -1, nil
+}
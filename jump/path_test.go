@@ -0,0 +1,192 @@
+package jump
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParsePath(t *testing.T) {
+	e := &fakeEditor{
+		tabs:    [][]fakeWindow{{{buf: 1}, {buf: 2, cwd: "/src/other"}}},
+		curTab:  1,
+		curWin:  1,
+		buffers: []Buffer{{Number: 1, Name: "/src/main.go", Loaded: true}, {Number: 2, Name: "lib.go", Loaded: true}},
+		cwd:     "/src",
+	}
+
+	tests := []struct {
+		text      string
+		window    int
+		path      string
+		line, col int
+		err       bool
+	}{
+		{"/bin/bash", -1, "/bin/bash", 0, 0, false},
+		{"main.go", -1, "/src/main.go", 0, 0, false},
+		{"main.go:100", -1, "/src/main.go", 100, 0, false},
+		{"  main.go:100:20  ", -1, "/src/main.go", 100, 20, false},
+		{"main.go:100:20: undefined", -1, "/src/main.go", 100, 20, false},
+		{"lib.go:3", 2, "/src/other/lib.go", 3, 0, false},
+		{"lib.go", 3, "", 0, 0, true},
+		{":10", -1, "", 0, 0, true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.text, func(t *testing.T) {
+			path, line, col, err := ParsePath(e, tc.text, tc.window)
+			if tc.err {
+				if err == nil {
+					t.Fatalf("expected an error but got %s %d %d", path, line, col)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("got error: %v", err)
+			}
+			if path != tc.path || line != tc.line || col != tc.col {
+				t.Fatalf("expected %s %d %d but got %s %d %d", tc.path, tc.line, tc.col, path, line, col)
+			}
+		})
+	}
+}
+
+func TestParseTarget(t *testing.T) {
+	abs := func(fpath string) (string, error) {
+		return AbsPathRelDir(fpath, "/src"), nil
+	}
+
+	tests := []struct {
+		text      string
+		path, url string
+		line, col int
+		how       string
+	}{
+		{"main.go:12:5", "/src/main.go", "", 12, 5, ResolvedRelativePath},
+		{"/etc/hosts:3", "/etc/hosts", "", 3, 0, ResolvedAbsolutePath},
+		{"file:///etc/hosts", "/etc/hosts", "", 0, 0, ResolvedFileURL},
+		{"https://example.com/x", "", "https://example.com/x", 0, 0, ResolvedURL},
+	}
+	for _, tc := range tests {
+		t.Run(tc.text, func(t *testing.T) {
+			target, how, err := ParseTarget(tc.text, abs)
+			if err != nil {
+				t.Fatalf("got error: %v", err)
+			}
+
+			url := ""
+			if target.URL != nil {
+				url = target.URL.String()
+			}
+			if target.Path != tc.path || url != tc.url || target.Line != tc.line || target.Col != tc.col || how != tc.how {
+				t.Fatalf("expected %s %s %d %d %s but got %s %s %d %d %s", tc.path, tc.url, tc.line, tc.col, tc.how,
+					target.Path, url, target.Line, target.Col, how)
+			}
+		})
+	}
+}
+
+func TestSamePath(t *testing.T) {
+	dir := t.TempDir()
+
+	file := filepath.Join(dir, "file.c")
+	if err := os.WriteFile(file, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(file, filepath.Join(dir, "link.c")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(dir, "sub"), filepath.Join(dir, "sublink")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		a, b   string
+		output bool
+	}{
+		{file, file, true},
+		{file, filepath.Join(dir, "sub", "..", "file.c"), true},
+		{file, filepath.Join(dir, "link.c"), true},
+		{file, filepath.Join(dir, "other.c"), false},
+		{filepath.Join(dir, "sub", "new.c"), filepath.Join(dir, "sublink", "new.c"), true},
+		{filepath.Join(dir, "sub", "new.c"), filepath.Join(dir, "sublink", "old.c"), false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.a+" "+tc.b, func(t *testing.T) {
+			r, err := SamePath(context.Background(), tc.a, tc.b)
+			if err != nil {
+				t.Fatalf("got error: %v", err)
+			}
+			if r != tc.output {
+				t.Fatalf("expected %v but got %v", tc.output, r)
+			}
+		})
+	}
+}
+
+func TestFirstExisting(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"b", "c"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p := func(name string) string {
+		return filepath.Join(dir, name)
+	}
+
+	tests := []struct {
+		name  string
+		paths []string
+		index int
+	}{
+		{"none", nil, -1},
+		{"first", []string{p("b"), p("c")}, 0},
+		{"later", []string{p("a"), "", p("c"), p("b")}, 2},
+		{"missing", []string{p("a"), p("d")}, -1},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			index, err := FirstExisting(context.Background(), tc.paths)
+			if err != nil {
+				t.Fatalf("got error: %v", err)
+			}
+			if index != tc.index {
+				t.Fatalf("expected %d but got %d", tc.index, index)
+			}
+		})
+	}
+}
+
+func TestPathExistsContextTimeout(t *testing.T) {
+	// Simulate a hung filesystem
+	hung := make(chan struct{})
+	defer close(hung)
+	stat = func(path string) (os.FileInfo, error) {
+		<-hung
+		return nil, os.ErrNotExist
+	}
+	defer func() { stat = os.Stat }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := PathExistsContext(ctx, "/hung/file")
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected context.DeadlineExceeded but got %v", err)
+	}
+
+	_, err = FirstExisting(ctx, []string{"/hung/a", "/hung/b"})
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected context.DeadlineExceeded but got %v", err)
+	}
+
+	_, err = SamePath(ctx, "/hung/a", "/hung/b")
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected context.DeadlineExceeded but got %v", err)
+	}
+}
@@ -0,0 +1,217 @@
+package jump
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+type SearchType int
+
+const (
+	SearchOnlyInCurrentTab SearchType = iota
+	SearchInAllTabs
+	// SearchNowhere doesn't search for the file in windows at all
+	SearchNowhere
+)
+
+// FindWindow returns a window that shows the file `fpath`, or nil if there is none.
+// Windows in the current tab page are preferred over those in other tab pages. The paths
+// are compared using SamePath, which gives up when `ctx` is done.
+func FindWindow(ctx context.Context, e Editor, fpath string, srchType SearchType) (win *Window, err error) {
+	if srchType == SearchNowhere {
+		return
+	}
+
+	fpath, err = AbsPath(e, fpath, -1)
+	if err != nil {
+		return
+	}
+
+	curTab, err := e.CurrentTab()
+	if err != nil {
+		return
+	}
+
+	// Search the current tab first, so that if the file is in a window in the
+	// current tab and another one, the local one is preferred.
+	findInList := func(wins []Window, skipCurrent bool) (*Window, error) {
+		for i, w := range wins {
+			if skipCurrent && w.Tab == curTab {
+				continue
+			}
+			same, err := SamePath(ctx, AbsPathRelDir(w.Name, w.Cwd), fpath)
+			if err != nil {
+				return nil, err
+			}
+			if same {
+				return &wins[i], nil
+			}
+		}
+		return nil, nil
+	}
+
+	wins, err := e.Windows(curTab)
+	if err != nil {
+		return
+	}
+	win, err = findInList(wins, false)
+	if err != nil || win != nil || srchType == SearchOnlyInCurrentTab {
+		return
+	}
+
+	wins, err = e.Windows(0)
+	if err != nil {
+		return
+	}
+	return findInList(wins, true)
+}
+
+// FindBuffer returns the number of a buffer that contains the file `fpath`, or 0 if
+// there is none. Hidden and unlisted buffers are included. It gives up when `ctx` is done.
+func FindBuffer(ctx context.Context, e Editor, fpath string) (bufnr int, err error) {
+	fpath, err = AbsPath(e, fpath, -1)
+	if err != nil {
+		return
+	}
+
+	bufs, err := e.Buffers()
+	if err != nil {
+		return
+	}
+
+	for _, buf := range bufs {
+		if buf.Name == "" {
+			continue
+		}
+
+		var name string
+		name, err = AbsPath(e, buf.Name, -1)
+		if err != nil {
+			return
+		}
+
+		var same bool
+		same, err = SamePath(ctx, name, fpath)
+		if err != nil {
+			return
+		}
+		if same {
+			// An unloaded buffer is only used if there is no loaded one.
+			if bufnr == 0 || buf.Loaded {
+				bufnr = buf.Number
+			}
+			if buf.Loaded {
+				break
+			}
+		}
+	}
+	return
+}
+
+// OpenCmds are the commands used to open a file that is not shown in a window.
+type OpenCmds struct {
+	// File opens a file
+	File string
+	// Dir opens a directory
+	Dir string
+	// Buffer is a format string for the command that shows an existing
+	// buffer. It is formatted with the buffer number.
+	Buffer string
+}
+
+// Opened describes how OpenOrChangeTo opened a file.
+type Opened int
+
+const (
+	// OpenedInWindow means a window that already showed the file was made current
+	OpenedInWindow Opened = iota
+	// OpenedBuffer means a hidden buffer that contained the file was shown
+	OpenedBuffer
+	// OpenedFile means the file was opened
+	OpenedFile
+)
+
+// OpenOrChangeTo ensures the file `fpath` is open in the editor. If the file is found in
+// a window using the search `srchType`, that window is made current. Otherwise `prepare`
+// is called to make the window the file should be opened from current and return the
+// commands to open it with, and the file is opened, reusing a hidden buffer that
+// contains it if there is one. Checking the filesystem gives up when `ctx` is done.
+func OpenOrChangeTo(ctx context.Context, e Editor, fpath string, srchType SearchType, prepare func() (OpenCmds, error)) (opened Opened, err error) {
+	win, err := FindWindow(ctx, e, fpath, srchType)
+	if err != nil {
+		return
+	}
+
+	if win != nil {
+		// Change to this window
+		var curTab int
+		curTab, err = e.CurrentTab()
+		if err != nil {
+			return
+		}
+		if win.Tab != curTab {
+			err = e.Command(fmt.Sprintf("%dtabnext", win.Tab))
+			if err != nil {
+				return
+			}
+		}
+
+		err = e.Command(fmt.Sprintf("%dwincmd w", win.Number))
+		opened = OpenedInWindow
+		return
+	}
+
+	cmds, err := prepare()
+	if err != nil {
+		return
+	}
+
+	// Not in a window, but the file may be loaded in a hidden buffer.
+	bufnr, err := FindBuffer(ctx, e, fpath)
+	if err != nil {
+		return
+	}
+
+	if bufnr != 0 {
+		err = e.Command(fmt.Sprintf(cmds.Buffer, bufnr))
+		opened = OpenedBuffer
+		return
+	}
+
+	// Seems like :split is not working from a script for directories for me
+	// (see https://superuser.com/questions/1243344/vim-wont-split-open-a-directory-from-python-but-it-works-interactively)
+	// so if it's a directory, use Hexplore instead.
+	dir, err := isDir(ctx, fpath)
+	if err != nil {
+		return
+	}
+	if dir {
+		err = e.Command(fmt.Sprintf("%s %s", cmds.Dir, fnameEscape(fpath)))
+	} else {
+		err = e.Command(fmt.Sprintf("%s %s", cmds.File, fnameEscape(fpath)))
+	}
+	opened = OpenedFile
+	return
+}
+
+// fnameEscapeChars are the characters that vim's fnameescape() escapes, since they would
+// otherwise separate arguments, expand or end the command.
+const fnameEscapeChars = " \t\n*?[{`$\\%#'\"|!<"
+
+// fnameEscape escapes the path `fpath` for use as a file argument of an Ex command, like
+// vim's fnameescape() does.
+func fnameEscape(fpath string) string {
+	if fpath == "-" {
+		return `\-`
+	}
+
+	var b strings.Builder
+	for i, r := range fpath {
+		if strings.ContainsRune(fnameEscapeChars, r) || (i == 0 && (r == '+' || r == '>')) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
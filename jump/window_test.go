@@ -0,0 +1,173 @@
+package jump
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// newTabsEditor returns an editor with two tab pages. The first, which is current,
+// shows main.go and lib/lib.go, and the second shows main_test.go and main.go.
+func newTabsEditor() *fakeEditor {
+	return &fakeEditor{
+		tabs: [][]fakeWindow{
+			{{buf: 1}, {buf: 2, cwd: "/src/lib"}},
+			{{buf: 3}, {buf: 1}},
+		},
+		curTab: 1,
+		curWin: 1,
+		buffers: []Buffer{
+			{Number: 1, Name: "/src/main.go", Loaded: true},
+			// lib.go is relative to the cwd of the window that shows it
+			{Number: 2, Name: "lib.go", Loaded: true},
+			{Number: 3, Name: "/src/main_test.go", Loaded: true},
+			{Number: 4, Name: "/src/hidden.go", Loaded: true},
+			{Number: 5, Name: "/src/unloaded.go", Loaded: false},
+			{Number: 6, Name: "/src/twice.go", Loaded: false},
+			{Number: 7, Name: "/src/twice.go", Loaded: true},
+			{Number: 8, Name: "", Loaded: true},
+		},
+		cwd: "/src",
+	}
+}
+
+func TestFindWindow(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		srchType SearchType
+		// tab and win are 0 if no window should be found
+		tab, win int
+	}{
+		{"current tab", "/src/main.go", SearchInAllTabs, 1, 1},
+		{"relative path", "main.go", SearchInAllTabs, 1, 1},
+		{"relative buffer name", "/src/lib/lib.go", SearchOnlyInCurrentTab, 1, 2},
+		{"other tab", "/src/main_test.go", SearchInAllTabs, 2, 1},
+		{"only current tab", "/src/main_test.go", SearchOnlyInCurrentTab, 0, 0},
+		{"nowhere", "/src/main.go", SearchNowhere, 0, 0},
+		{"hidden buffer", "/src/hidden.go", SearchInAllTabs, 0, 0},
+		{"unclean path", "/src/lib/../main.go", SearchInAllTabs, 1, 1},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			win, err := FindWindow(context.Background(), newTabsEditor(), tc.path, tc.srchType)
+			if err != nil {
+				t.Fatalf("got error: %v", err)
+			}
+
+			tab, num := 0, 0
+			if win != nil {
+				tab, num = win.Tab, win.Number
+			}
+			if tab != tc.tab || num != tc.win {
+				t.Fatalf("expected tab %d window %d but got tab %d window %d", tc.tab, tc.win, tab, num)
+			}
+		})
+	}
+
+	// When the file is in the current tab and another one, the current one is preferred.
+	e := newTabsEditor()
+	e.curTab = 2
+	win, err := FindWindow(context.Background(), e, "/src/main.go", SearchInAllTabs)
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	if win == nil || win.Tab != 2 || win.Number != 2 {
+		t.Fatalf("expected tab 2 window 2 but got %+v", win)
+	}
+}
+
+func TestFindBuffer(t *testing.T) {
+	tests := []struct {
+		path  string
+		bufnr int
+	}{
+		{"/src/hidden.go", 4},
+		{"hidden.go", 4},
+		{"/src/unloaded.go", 5},
+		{"/src/twice.go", 7},
+		{"/src/missing.go", 0},
+	}
+	for _, tc := range tests {
+		t.Run(tc.path, func(t *testing.T) {
+			bufnr, err := FindBuffer(context.Background(), newTabsEditor(), tc.path)
+			if err != nil {
+				t.Fatalf("got error: %v", err)
+			}
+			if bufnr != tc.bufnr {
+				t.Fatalf("expected buffer %d but got %d", tc.bufnr, bufnr)
+			}
+		})
+	}
+}
+
+func TestOpenOrChangeTo(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	split := OpenCmds{File: "split", Dir: "Hexplore", Buffer: "sbuffer %d"}
+
+	tests := []struct {
+		name     string
+		path     string
+		srchType SearchType
+		opened   Opened
+		commands []string
+	}{
+		{"window in current tab", "/src/lib/lib.go", SearchInAllTabs, OpenedInWindow, []string{"2wincmd w"}},
+		{"window in other tab", "/src/main_test.go", SearchInAllTabs, OpenedInWindow, []string{"2tabnext", "1wincmd w"}},
+		{"window not searched", "/src/main.go", SearchNowhere, OpenedBuffer, []string{"prepare", "sbuffer 1"}},
+		{"hidden buffer", "/src/hidden.go", SearchInAllTabs, OpenedBuffer, []string{"prepare", "sbuffer 4"}},
+		{"new file", "/src/new.go", SearchInAllTabs, OpenedFile, []string{"prepare", "split /src/new.go"}},
+		{"directory", filepath.Join(dir, "sub"), SearchInAllTabs, OpenedFile,
+			[]string{"prepare", "Hexplore " + filepath.Join(dir, "sub")}},
+		{"special characters", "/src/my file|x%.go", SearchInAllTabs, OpenedFile,
+			[]string{"prepare", `split /src/my\ file\|x\%.go`}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			e := newTabsEditor()
+			prepare := func() (OpenCmds, error) {
+				e.commands = append(e.commands, "prepare")
+				return split, nil
+			}
+
+			opened, err := OpenOrChangeTo(context.Background(), e, tc.path, tc.srchType, prepare)
+			if err != nil {
+				t.Fatalf("got error: %v", err)
+			}
+			if opened != tc.opened {
+				t.Fatalf("expected opened %d but got %d", tc.opened, opened)
+			}
+			if !reflect.DeepEqual(e.commands, tc.commands) {
+				t.Fatalf("expected commands %q but got %q", tc.commands, e.commands)
+			}
+		})
+	}
+}
+
+func TestFnameEscape(t *testing.T) {
+	tests := []struct {
+		path, expected string
+	}{
+		{"/src/main.go", "/src/main.go"},
+		{"/src/my file.go", `/src/my\ file.go`},
+		{"/src/a|!cmd", `/src/a\|\!cmd`},
+		{"/src/%#.go", `/src/\%\#.go`},
+		{`/src/it's "x".go`, `/src/it\'s\ \"x\".go`},
+		{"+cmd", `\+cmd`},
+		{"a+b", "a+b"},
+		{"-", `\-`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.path, func(t *testing.T) {
+			if r := fnameEscape(tc.path); r != tc.expected {
+				t.Fatalf("expected %s but got %s", tc.expected, r)
+			}
+		})
+	}
+}
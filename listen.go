@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jeffwilliams/basejump/jump"
+	"github.com/jeffwilliams/basejump/server"
+)
+
+// Listen accepts requests to open paths on the unix socket `path`, which may be
+// "default" to use the default socket. It only returns if listening fails.
+func (n Basejump) Listen(path string) {
+	if path == "default" {
+		path = server.DefaultPath()
+	}
+
+	l, err := server.Listen(path)
+	if err != nil {
+		n.Echom("error: can't listen for requests: %v", err)
+		return
+	}
+	trace(n, "trace: Listen: listening on %s", path)
+
+	err = server.Serve(l, n.HandleRequest)
+	n.Echom("error: stopped listening for requests: %v", err)
+}
+
+// HandleRequest opens the text in the request `req`, which was received on the socket.
+func (n Basejump) HandleRequest(req server.Request) (resp server.Response) {
+	defer func() {
+		// Report the panic to the client, which would otherwise print an empty location
+		if v := recover(); v != nil {
+			recordPanic(v)
+			resp = server.Response{Error: fmt.Sprintf("internal error: %v", v)}
+		}
+	}()
+
+	ctx, cancel := n.state.start()
+	defer cancel()
+
+	n.state.mu.Lock()
+	defer n.state.mu.Unlock()
+
+	n = n.withConfig()
+	n.ctx = ctx
+
+	t, err := n.openRequest(req)
+	if err != nil {
+		logger.Error("request failed", "text", req.Text, "err", err)
+		resp.Error = err.Error()
+		return
+	}
+
+	resp.Path, resp.Line, resp.Col = t.Path, t.Line, t.Col
+	if t.URL != nil {
+		resp.Path = t.URL.String()
+	}
+	return
+}
+
+func (n Basejump) openRequest(req server.Request) (t jump.Target, err error) {
+	method := req.Method
+	if method == "" {
+		method = n.config().OpenMode
+	}
+
+	text := expandHome(strings.TrimSpace(req.Text))
+	trace(n, "trace: openRequest: opening '%s' relative to '%s'", text, req.Cwd)
+
+	abs := n.absInWindow(-1)
+	if req.Cwd != "" {
+		abs = absRelDir(req.Cwd)
+	}
+
+	c, err := n.resolveTexts([]string{text}, -1, req.Cwd)
+	if err != nil {
+		return
+	}
+
+	t, err = n.resolveCandidate(c, abs)
+	if err != nil {
+		return
+	}
+
+	if t.URL != nil {
+		err = n.OpenRemoteUrl(t.URL, method)
+		return
+	}
+
+	err = n.OpenPathAtLineCol(t.Path, t.Line, t.Col, method)
+	if err != nil {
+		return
+	}
+
+	n.recordJump(c.String(), "[socket]", t.Path, t.Line, t.Col)
+	return
+}
+
+// expandHome expands a leading tilde in `text` into the home directory.
+func expandHome(text string) string {
+	if text != "~" && !strings.HasPrefix(text, "~/") {
+		return text
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return text
+	}
+	return filepath.Join(home, text[1:])
+}
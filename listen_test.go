@@ -0,0 +1,72 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jeffwilliams/basejump/server"
+)
+
+func TestHandleRequestPanic(t *testing.T) {
+	// A Basejump without state panics as soon as it handles a request
+	resp := Basejump{}.HandleRequest(server.Request{Text: "main.go"})
+	if !strings.HasPrefix(resp.Error, "internal error: ") {
+		t.Fatalf("expected the panic to be reported but got %+v", resp)
+	}
+}
+
+func TestSocketOpensPathWithBar(t *testing.T) {
+	n := embeddedBasejump(t)
+	nv := n.nvim()
+
+	// If the path were not escaped, the text after the bar would run as a command.
+	dir := t.TempDir()
+	name := "a | let g:injected = 1"
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte("x\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Socket paths are limited in length, so don't use t.TempDir()
+	sockDir, err := os.MkdirTemp("", "bj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(sockDir)
+
+	sock := filepath.Join(sockDir, "basejump.sock")
+	l, err := server.Listen(sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go server.Serve(l, n.HandleRequest)
+
+	resp, err := server.Send(sock, server.Request{Text: name, Cwd: dir})
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if resp.Path != path {
+		t.Fatalf("expected %s to be opened but got %+v", path, resp)
+	}
+
+	var opened string
+	err = nv.Call("expand", &opened, "%:p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opened != path {
+		t.Fatalf("expected the current buffer to be %s but got %s", path, opened)
+	}
+
+	var injected int
+	err = nv.Eval("exists('g:injected')", &injected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if injected != 0 {
+		t.Fatalf("the text after the bar was run as a command")
+	}
+}
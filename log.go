@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+
+	"github.com/jeffwilliams/basejump/logging"
+)
+
+var optLog = flag.String("log", "", "the file to log to. The plugin passes basejump.log in stdpath('log')")
+var optLogLevel = flag.String("loglevel", "info", "the level of the least severe messages logged: "+
+	strings.Join(logging.LevelNames, ", "))
+
+var optLogPanic = flag.Bool("logpanic", false, "also write panics to the file /tmp/basejump.panic. Panics are always logged")
+
+// logLevel is the level of the least severe messages logged. :BasejumpLog changes it.
+var logLevel = new(slog.LevelVar)
+
+// logger is basejump's log. Until the log file is opened nothing is logged.
+var logger = logging.New(io.Discard, logLevel)
+
+// logPath returns the file basejump logs to, set by -log. Without it this is where
+// stdpath('log') would put basejump.log.
+func logPath() (string, error) {
+	if *optLog != "" {
+		return *optLog, nil
+	}
+
+	state := os.Getenv("XDG_STATE_HOME")
+	if state == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		state = filepath.Join(home, ".local", "state")
+	}
+	appName := os.Getenv("NVIM_APPNAME")
+	if appName == "" {
+		appName = "nvim"
+	}
+	return filepath.Join(state, appName, "basejump.log"), nil
+}
+
+// openLog opens the log file set by the flags. Failures are reported on stderr, which
+// nvim shows, and leave logging disabled.
+func openLog() {
+	level, err := logging.ParseLevel(*optLogLevel)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "basejump: %v\n", err)
+	}
+	if *optTrace {
+		level = slog.LevelDebug
+	}
+	logLevel.Set(level)
+
+	path, err := logPath()
+	if err == nil {
+		logger, err = logging.Open(path, logLevel)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "basejump: can't open the log file: %v\n", err)
+		logger = logging.New(io.Discard, logLevel)
+		return
+	}
+	logger.Info("started", "pid", os.Getpid(), "args", strings.Join(os.Args[1:], " "))
+}
+
+// logPanic logs a panic with its stack trace. It must be deferred, and stops the panic
+// from killing basejump. With -logpanic the panic is also written to /tmp/basejump.panic.
+func logPanic() {
+	if v := recover(); v != nil {
+		recordPanic(v)
+	}
+}
+
+// recordPanic logs the recovered panic value `v` like logPanic does, for handlers that
+// must also report the panic to their caller.
+func recordPanic(v interface{}) {
+	stack := debug.Stack()
+	logger.Error("panic", "value", v, "stack", string(stack))
+
+	if *optLogPanic {
+		f, err := os.Create("/tmp/basejump.panic")
+		if err != nil {
+			return
+		}
+		fmt.Fprintf(f, "%s\n", v)
+		fmt.Fprintf(f, "%s\n", stack)
+		f.Close()
+	}
+}
+
+// trace logs a message at the debug level. With -trace it is also echoed to the messages
+// history.
+func trace(n Basejump, format string, args ...interface{}) {
+	if !*optTrace && !logger.Enabled(context.Background(), slog.LevelDebug) {
+		return
+	}
+
+	msg := fmt.Sprintf(format, args...)
+	logger.Debug(strings.TrimPrefix(msg, "trace: "))
+	if *optTrace {
+		n.Echom("%s", msg)
+	}
+}
+
+// logCommand handles :BasejumpLog [level]. With a level it sets the level of the least
+// severe messages logged, and otherwise opens the log at its end.
+func (a Basejump) logCommand(args []string) {
+	defer logPanic()
+
+	if len(args) > 0 {
+		level, err := logging.ParseLevel(args[0])
+		if err != nil {
+			a.Echom("error: %v", err)
+			return
+		}
+		logLevel.Set(level)
+		logger.Info("log level changed", "level", logging.LevelName(level))
+		a.Echom("basejump: logging at the %s level", logging.LevelName(level))
+		return
+	}
+
+	a.run(func(n Basejump) error {
+		path, err := logPath()
+		if err != nil {
+			return err
+		}
+
+		lines, err := countLines(path)
+		if err != nil {
+			return err
+		}
+
+		// Reload the log if it is already open
+		err = n.nvim().Command("checktime")
+		if err != nil {
+			return err
+		}
+		return n.OpenPathAtLineCol(path, lines, 1, n.config().OpenMode)
+	})
+}
+
+// countLines returns the number of lines in the file `path`.
+func countLines(path string) (lines int, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	for {
+		var b []byte
+		b, err = r.ReadSlice('\n')
+		if len(b) > 0 && b[len(b)-1] == '\n' {
+			lines++
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err == io.EOF {
+			return lines, nil
+		}
+		if err != nil {
+			return
+		}
+	}
+}
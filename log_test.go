@@ -0,0 +1,40 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCountLines(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+		lines    int
+	}{
+		{"empty", "", 0},
+		{"one", "level=info msg=started\n", 1},
+		{"several", "a\nb\nc\n", 3},
+		{"unterminated", "a\nb", 1},
+		{"long", string(make([]byte, 10000)) + "\n\n", 2},
+	}
+
+	dir := t.TempDir()
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(dir, tc.name+".log")
+			err := os.WriteFile(path, []byte(tc.contents), 0600)
+			if err != nil {
+				t.Fatalf("writing the file failed: %v", err)
+			}
+
+			lines, err := countLines(path)
+			if err != nil {
+				t.Fatalf("got error: %v", err)
+			}
+			if lines != tc.lines {
+				t.Fatalf("expected %d lines but got %d", tc.lines, lines)
+			}
+		})
+	}
+}
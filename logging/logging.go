@@ -0,0 +1,81 @@
+// Package logging opens basejump's log. Lines are written by slog's text handler in the
+// logfmt format, made of key=value pairs:
+//
+//	time=2024-03-01T10:04:05.123Z level=info msg=jump path=/src/main.go line=12
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LevelNames are the names of the levels, in order of severity.
+var LevelNames = []string{"debug", "info", "warn", "error"}
+
+var levels = []slog.Level{slog.LevelDebug, slog.LevelInfo, slog.LevelWarn, slog.LevelError}
+
+// ParseLevel parses the name of a level.
+func ParseLevel(s string) (slog.Level, error) {
+	for i, name := range LevelNames {
+		if strings.EqualFold(s, name) {
+			return levels[i], nil
+		}
+	}
+	return slog.LevelInfo, fmt.Errorf("invalid log level '%s'. Expected one of %s", s, strings.Join(LevelNames, ", "))
+}
+
+// LevelName returns the name of `level` as it is written in LevelNames.
+func LevelName(level slog.Level) string {
+	return strings.ToLower(level.String())
+}
+
+// MaxSize is the size in bytes beyond which Open moves a log file aside and starts a new one.
+const MaxSize = 5 << 20
+
+// New returns a logger that writes the lines at or above `level` to `w`. The level can be
+// changed while logging by passing a *slog.LevelVar.
+func New(w io.Writer, level slog.Leveler) *slog.Logger {
+	return slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{Level: level, ReplaceAttr: replaceAttr}))
+}
+
+// replaceAttr writes times in UTC to the millisecond, and levels in lower case as they are
+// named in the options.
+func replaceAttr(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) > 0 {
+		return a
+	}
+
+	switch {
+	case a.Key == slog.TimeKey && a.Value.Kind() == slog.KindTime:
+		a.Value = slog.StringValue(a.Value.Time().UTC().Format("2006-01-02T15:04:05.000Z07:00"))
+	case a.Key == slog.LevelKey:
+		if level, ok := a.Value.Any().(slog.Level); ok {
+			a.Value = slog.StringValue(LevelName(level))
+		}
+	}
+	return a
+}
+
+// Open returns a logger that appends the lines at or above `level` to the file `path`,
+// creating it and its directory if needed. If the file is larger than MaxSize it is first
+// renamed to `path` with ".1" appended, replacing the previous one.
+func Open(path string, level slog.Leveler) (*slog.Logger, error) {
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return nil, err
+	}
+
+	if info, err := os.Stat(path); err == nil && info.Size() > MaxSize {
+		os.Rename(path, path+".1")
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return New(f, level), nil
+}
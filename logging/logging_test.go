@@ -0,0 +1,146 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// withoutTime returns the line `line` without its leading time.
+func withoutTime(line string) string {
+	if !strings.HasPrefix(line, "time=") {
+		return line
+	}
+	_, rest, _ := strings.Cut(line, " ")
+	return rest
+}
+
+func TestLog(t *testing.T) {
+	tests := []struct {
+		name     string
+		level    slog.Level
+		msg      string
+		keyvals  []interface{}
+		expected string
+	}{
+		{"message", slog.LevelInfo, "jump", nil, `level=info msg=jump`},
+		{"pairs", slog.LevelWarn, "jump", []interface{}{"path", "/src/main.go", "line", 12},
+			`level=warn msg=jump path=/src/main.go line=12`},
+		{"quoted", slog.LevelError, "jump failed", []interface{}{"err", errors.New(`no such file "a b"`), "text", ""},
+			`level=error msg="jump failed" err="no such file \"a b\"" text=""`},
+		{"multiline", slog.LevelError, "panic", []interface{}{"stack", "main.go:1\nmain.go:2"},
+			`level=error msg=panic stack="main.go:1\nmain.go:2"`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var b strings.Builder
+			l := New(&b, slog.LevelDebug)
+
+			l.Log(context.Background(), tc.level, tc.msg, tc.keyvals...)
+			if withoutTime(b.String()) != tc.expected+"\n" {
+				t.Fatalf("expected\n%s\nbut got\n%s", tc.expected, b.String())
+			}
+		})
+	}
+}
+
+func TestTime(t *testing.T) {
+	var b strings.Builder
+	New(&b, slog.LevelInfo).Info("jump")
+
+	stamp, _, _ := strings.Cut(strings.TrimPrefix(b.String(), "time="), " ")
+	if _, err := time.Parse("2006-01-02T15:04:05.000Z", stamp); err != nil {
+		t.Fatalf("expected a UTC time to the millisecond but got %s: %v", stamp, err)
+	}
+}
+
+func TestLevel(t *testing.T) {
+	var b strings.Builder
+	level := new(slog.LevelVar)
+	level.Set(slog.LevelWarn)
+	l := New(&b, level)
+
+	l.Debug("debug")
+	l.Info("info")
+	l.Warn("warn")
+	l.Error("error")
+	if lines := strings.Count(b.String(), "\n"); lines != 2 {
+		t.Fatalf("expected 2 lines at warn but got %d:\n%s", lines, b.String())
+	}
+
+	level.Set(slog.LevelDebug)
+	if !l.Enabled(context.Background(), slog.LevelDebug) {
+		t.Fatalf("expected debug to be enabled")
+	}
+	l.Debug("debug")
+	if !strings.Contains(b.String(), "level=debug msg=debug") {
+		t.Fatalf("expected a debug line but got:\n%s", b.String())
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for i, name := range LevelNames {
+		level, err := ParseLevel(strings.ToUpper(name))
+		if err != nil || level != levels[i] || LevelName(level) != name {
+			t.Fatalf("expected %s to parse as level %v but got %v, %v", name, levels[i], level, err)
+		}
+	}
+
+	_, err := ParseLevel("verbose")
+	if err == nil {
+		t.Fatalf("expected an error for an invalid level")
+	}
+}
+
+func TestOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "basejump.log")
+
+	l, err := Open(path, slog.LevelInfo)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	l.Info("first")
+
+	l, err = Open(path, slog.LevelInfo)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	l.Info("second")
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading the log failed: %v", err)
+	}
+	if !strings.Contains(string(data), "msg=first") || !strings.Contains(string(data), "msg=second") {
+		t.Fatalf("expected both lines to be appended but got:\n%s", data)
+	}
+}
+
+func TestOpenRotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "basejump.log")
+	err := os.WriteFile(path, make([]byte, MaxSize+1), 0600)
+	if err != nil {
+		t.Fatalf("writing the log failed: %v", err)
+	}
+
+	l, err := Open(path, slog.LevelInfo)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	l.Info("fresh")
+
+	info, err := os.Stat(path + ".1")
+	if err != nil || info.Size() != MaxSize+1 {
+		t.Fatalf("expected the old log to be moved aside but got %v, %v", info, err)
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.HasSuffix(string(data), "msg=fresh\n") || len(data) > 100 {
+		t.Fatalf("expected a new log but got %d bytes", len(data))
+	}
+}
@@ -0,0 +1,20 @@
+-- Configures basejump from Lua. The options are the keys of g:basejump, which
+-- are described in the README.
+local M = {}
+
+-- setup merges `opts` into g:basejump.
+function M.setup(opts)
+  vim.g.basejump = vim.tbl_deep_extend('force', vim.g.basejump or {}, opts or {})
+end
+
+-- register_resolver registers the function `fn` as the custom resolver `name`.
+-- See basejump#register_resolver().
+function M.register_resolver(name, fn)
+  vim.fn['basejump#register_resolver'](name, fn)
+end
+
+function M.unregister_resolver(name)
+  vim.fn['basejump#unregister_resolver'](name)
+end
+
+return M
@@ -2,27 +2,66 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"flag"
 	"fmt"
 	"net/url"
 	"os"
 	"os/exec"
-	"path"
-	"regexp"
-	"runtime/debug"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
+	"unicode/utf8"
 
-	"github.com/jeffwilliams/basejump/diff"
+	"github.com/jeffwilliams/basejump/jump"
+	"github.com/jeffwilliams/basejump/server"
 	"github.com/neovim/go-client/nvim"
 	"github.com/neovim/go-client/nvim/plugin"
 )
 
 type Basejump struct {
-	P *plugin.Plugin
+	P     *plugin.Plugin
+	state *jumpState
+	// target is the nvim instance that jumps are made in, when it is not
+	// the instance that basejump is a plugin of. See g:basejump_target_server.
+	target *nvim.Nvim
+	// ctx is canceled when the jump being made is superseded by another one
+	ctx context.Context
+	// cfg is the configuration read when the jump being made started
+	cfg *config
 }
 
+// jumpState is the state basejump keeps between jumps.
+type jumpState struct {
+	// reuseWinID is the id of the window that jumps are loaded into
+	// when using the 'reuse' open mode.
+	reuseWinID int
+	// server is the connection to the target server and its state, if there is one
+	server *targetServer
+	// mu is held while handling a request, so that requests from vim and from
+	// the socket don't interleave
+	mu sync.Mutex
+
+	// cancel cancels the jump in progress. It is protected by cancelMu
+	// rather than mu so that a new jump can cancel the one holding mu.
+	cancel   context.CancelFunc
+	cancelMu sync.Mutex
+
+	// config is the configuration parsed from the settings that configKey describes. It
+	// is protected by configMu, since it is read by functions that don't hold mu.
+	config       config
+	configKey    string
+	configLoaded bool
+	configMu     sync.Mutex
+}
+
+// nvim returns the nvim instance to operate on. This is the instance basejump
+// is a plugin of, unless jumps are being made in a target server.
 func (n Basejump) nvim() *nvim.Nvim {
+	if n.target != nil {
+		return n.target
+	}
 	return n.P.Nvim
 }
 
@@ -59,7 +98,8 @@ func (n Basejump) Selection() (startLine, startCol, endLine, endCol int, err err
 	return
 }
 
-// Return the current line and column of the cursor
+// Return the current line and column of the cursor. The column is a 1-based byte
+// column, as used by getpos() and cursor().
 func (n Basejump) Cursor() (line, col int, err error) {
 	result := make([]float32, 4)
 	nv := n.nvim()
@@ -74,8 +114,47 @@ func (n Basejump) Cursor() (line, col int, err error) {
 	return
 }
 
-// SelectionText returns the text contained in the current selection.
-func (n Basejump) SelectionText() (text string, err error) {
+// cursorState is the line the cursor is on and its 1-based byte column. The vim functions
+// that open the path under the cursor capture it when they are called, since the cursor
+// may have moved by the time the jump is made.
+type cursorState struct {
+	Text string `msgpack:"text" eval:"getline('.')"`
+	Col  int    `msgpack:"col" eval:"col('.')"`
+}
+
+// cursorState returns the current state of the cursor.
+func (n Basejump) cursorState() (cur cursorState, err error) {
+	cur.Text, err = n.CurrentLineText()
+	if err != nil {
+		return
+	}
+	_, cur.Col, err = n.Cursor()
+	return
+}
+
+// open and preview are OpenPathUnderCursor and Preview for the cursor `cur`.
+func (cur cursorState) open(n Basejump, method string) error {
+	return n.OpenPathUnderCursor(cur, method)
+}
+
+func (cur cursorState) preview(n Basejump, method string) error {
+	return n.Preview(cur, method)
+}
+
+// selectionState is the last visual selection: the lines it spans, the positions of its
+// '< and '> marks as returned by getpos(), its type as returned by visualmode(), and the
+// options its text depends on. Like cursorState it is captured when a vim function is called.
+type selectionState struct {
+	Lines     []string `msgpack:"lines" eval:"getline(line(\"'<\"), line(\"'>\"))"`
+	Start     [4]int   `msgpack:"start" eval:"getpos(\"'<\")"`
+	End       [4]int   `msgpack:"end" eval:"getpos(\"'>\")"`
+	Mode      string   `msgpack:"mode" eval:"visualmode()"`
+	Selection string   `msgpack:"selection" eval:"&selection"`
+	Tabstop   int      `msgpack:"tabstop" eval:"&tabstop"`
+}
+
+// selectionState returns the current state of the last visual selection.
+func (n Basejump) selectionState() (s selectionState, err error) {
 	nv := n.nvim()
 
 	var startLine, startCol, endLine, endCol int
@@ -83,36 +162,161 @@ func (n Basejump) SelectionText() (text string, err error) {
 	if err != nil {
 		return
 	}
+	s.Start = [4]int{0, startLine, startCol, 0}
+	s.End = [4]int{0, endLine, endCol, 0}
 
-	trace(n, "selected text is from line %d col %d to line %d col %d", startLine, startCol, endLine, endCol)
+	err = nv.Call("visualmode", &s.Mode)
+	if err != nil {
+		return
+	}
 
-	var buf nvim.Buffer
-	buf, err = nv.CurrentBuffer()
+	err = nv.Eval("&selection", &s.Selection)
 	if err != nil {
 		return
 	}
 
-	var lines [][]byte
-	// Indexing is zero-based, end-exclusive
-	lines, err = nv.BufferLines(buf, startLine-1, endLine, true)
+	err = nv.Eval("&tabstop", &s.Tabstop)
 	if err != nil {
 		return
 	}
 
+	s.Lines, err = n.Lines(startLine, endLine)
+	return
+}
+
+// texts returns the text contained in the selection. A characterwise selection results
+// in a single element. For linewise and blockwise selections each line of the selection
+// is returned as a separate element, since each may be a separate path.
+func (s selectionState) texts() []string {
+	return selectionSegments(s.Lines, s.Mode, s.Start[2], s.End[2], s.Selection == "exclusive", s.Tabstop)
+}
+
+// open is OpenSelectedPath for the selection `s`.
+func (s selectionState) open(n Basejump, method string) error {
+	return n.OpenSelectedPath(s, method)
+}
+
+const (
+	visualCharwise  = "v"
+	visualLinewise  = "V"
+	visualBlockwise = "\x16"
+)
+
+// selectionSegments extracts the selected text from `lines`, which are the lines
+// spanned by a visual selection of type `mode` (as returned by visualmode()).
+// `startCol` and `endCol` are the 1-based byte columns of the '< and '> marks. They may
+// lie outside of the line (as they do for linewise selections) and are clamped.
+// If `exclusive` is true the character at `endCol` is not part of the selection.
+func selectionSegments(lines []string, mode string, startCol, endCol int, exclusive bool, tabstop int) []string {
+	if len(lines) == 0 {
+		return nil
+	}
+
+	switch mode {
+	case visualLinewise:
+		return lines
+	case visualBlockwise:
+		return blockSegments(lines, startCol, endCol, exclusive, tabstop)
+	}
+
 	var bbuf bytes.Buffer
+	first, last := lines[0], lines[len(lines)-1]
+
+	start := clampByteIndex(first, startCol-1)
+	end := selectionEnd(last, endCol, exclusive)
 
 	if len(lines) == 1 {
-		bbuf.Write(lines[0][startCol-1 : endCol])
-	} else if len(lines) > 1 {
-		bbuf.Write(lines[0][startCol-1 : len(lines[0])])
+		if start < end {
+			bbuf.WriteString(first[start:end])
+		}
+	} else {
+		bbuf.WriteString(first[start:])
 		for i := 1; i < len(lines)-1; i++ {
-			bbuf.Write(lines[i])
+			bbuf.WriteString(lines[i])
 		}
-		bbuf.Write(lines[len(lines)-1][0:endCol])
+		bbuf.WriteString(last[:end])
 	}
 
-	text = bbuf.String()
-	return
+	return []string{bbuf.String()}
+}
+
+// selectionEnd returns the byte index one past the end of a selection that ends at the 1-based
+// byte column `endCol`. If the selection is inclusive the whole character at `endCol` is included.
+func selectionEnd(s string, endCol int, exclusive bool) int {
+	end := clampByteIndex(s, endCol-1)
+	if !exclusive && end < len(s) {
+		_, size := utf8.DecodeRuneInString(s[end:])
+		end += size
+	}
+	return end
+}
+
+// clampByteIndex limits the byte index `i` to the string `s` and moves it back to
+// the start of the character it falls within.
+func clampByteIndex(s string, i int) int {
+	if i < 0 {
+		return 0
+	}
+	if i >= len(s) {
+		return len(s)
+	}
+	for i > 0 && !utf8.RuneStart(s[i]) {
+		i--
+	}
+	return i
+}
+
+// blockSegments extracts the text of a blockwise selection from each line. The block is
+// delimited by the screen columns of the characters at the two corners of the selection.
+func blockSegments(lines []string, startCol, endCol int, exclusive bool, tabstop int) []string {
+	sl, sr := screenCols(lines[0], startCol, tabstop)
+	el, er := screenCols(lines[len(lines)-1], endCol, tabstop)
+
+	left, right := sl, sr
+	if el < left {
+		left = el
+	}
+	if er > right {
+		right = er
+	}
+	if exclusive {
+		right = sl
+		if el > right {
+			right = el
+		}
+		right--
+	}
+
+	segs := make([]string, 0, len(lines))
+	for _, l := range lines {
+		var bbuf bytes.Buffer
+		vcol := 1
+		for _, r := range l {
+			w := runeWidth(r, vcol, tabstop)
+			if vcol >= left && vcol+w-1 <= right {
+				bbuf.WriteRune(r)
+			}
+			vcol += w
+		}
+		segs = append(segs, bbuf.String())
+	}
+	return segs
+}
+
+// screenCols returns the first and last 1-based screen column occupied by the character
+// at the 1-based byte column `col` of `s`. Columns past the end of the line are treated
+// as if the line were padded with spaces.
+func screenCols(s string, col, tabstop int) (first, last int) {
+	vcol := 1
+	for i, r := range s {
+		w := runeWidth(r, vcol, tabstop)
+		if i+utf8.RuneLen(r) >= col {
+			return vcol, vcol + w - 1
+		}
+		vcol += w
+	}
+	vcol += col - len(s) - 1
+	return vcol, vcol
 }
 
 // CurrentWordText returns the current word under the cursor
@@ -140,281 +344,326 @@ func (n Basejump) LineText(line int) (text string, err error) {
 	return
 }
 
-var pathRegex = regexp.MustCompile(`^([^:]+)(?::(\d+))?(?::(\d+))?`)
+func (n Basejump) Lines(first, last int) (lines []string, err error) {
+	nv := n.nvim()
+	err = nv.Call("getline", &lines, first, last)
+	return
+}
 
-// ParsePath parses `text` into a filesystem path, line, and column. The `text`
-// parameter must have one of the formats:
-//
-//    <path>								(for example file.go, or /bin/bash)
-//    <path>:<line>					(for example file.go:100)
-//    <path>:<line>:<col>		(for example file.go:100:20)
-//
-// If the parsed path is not absolute it is made absolute by prepending the
-// cwd of the current window in vim.
-//
-// If line and or col is missing, they are set to 0.
-func (n Basejump) ParsePath(text string) (fpath string, line, col int, err error) {
-	text = strings.TrimSpace(text)
+// OpenOrChangeTo ensures the specified file is open in vim. If the path is found in a
+// window, that window is made current. If no window contains that path, it is split and
+// opened.
+func (n Basejump) OpenOrChangeTo(fpath, method string) (wasOpen bool, err error) {
+	nv := n.nvim()
 
-	match := pathRegex.FindStringSubmatch(text)
-	if match == nil || len(match) < 2 {
-		err = fmt.Errorf("doesn't seem to be a valid path")
-		return
-	}
-	fpath = match[1]
-	if len(match) > 2 && match[2] != "" {
-		line, err = strconv.Atoi(match[2])
-		if err != nil {
-			return
-		}
-	}
-	if len(match) > 3 && match[3] != "" {
-		col, err = strconv.Atoi(match[3])
+	var srchType jump.SearchType = jump.SearchInAllTabs
+	useLast := false
+	if method == openBySwitchbuf {
+		var switchbuf string
+		err = nv.Eval("&switchbuf", &switchbuf)
 		if err != nil {
 			return
 		}
+		srchType, method, useLast = parseSwitchbuf(switchbuf)
+		trace(n, "trace: SplitOrChangeTo: switchbuf=%s: search type %d, open mode %s", switchbuf, srchType, method)
 	}
 
-	fpath, err = n.AbsPath(fpath)
+	fromTerminal, err := n.inTerminal()
 	if err != nil {
 		return
 	}
-	return
-}
-
-// AbsPath makes the path `fpath` absolute if it is not by prepending
-// the working directory of the current window.
-func (n Basejump) AbsPath(fpath string) (result string, err error) {
-	return n.AbsPathRelWindow(fpath, -1)
-}
-
-func (n Basejump) pidCwd(pid int) (string, error) {
-	return os.Readlink(fmt.Sprintf("/proc/%d/cwd", pid))
-}
-
-// AbsPathRelWindow makes the path `fpath` absolute if it is not by prepending
-// the working directory of the window `window`.
-func (n Basejump) AbsPathRelWindow(fpath string, window int) (result string, err error) {
-	nv := n.nvim()
 
-	result = fpath
-	if !path.IsAbs(fpath) {
+	prepare := func() (cmds jump.OpenCmds, err error) {
+		trace(n, "trace: SplitOrChangeTo: no window matches. opening %s using %s.", fpath, method)
 
-		var cwd string
-
-		if window == -1 {
-			// If the current window is a terminal window, then we need
-			// to get the cwd in a special way. We can't use this method for
-			// the non-current window.
-			var b bool
-			err = nv.Call("exists", &b, "b:term_title")
+		if useLast {
+			// Open in the previous window, like quickfix commands do
+			// for 'switchbuf' uselast.
+			err = nv.Command("wincmd p")
 			if err != nil {
 				return
 			}
-			if b {
-				// Is a terminal.
-				var pid float32
-				var buf nvim.Buffer
-				buf, err = nv.CurrentBuffer()
-				if err != nil {
-					return
-				}
-				err = nv.BufferVar(buf, "terminal_job_pid", &pid)
-				if err != nil {
-					return
-				}
-
-				cwd, err = n.pidCwd(int(pid))
-				if err != nil {
-					return
-				}
-			}
-
 		}
 
-		if cwd == "" {
-
-			args := make([]interface{}, 0, 1)
-			if window != -1 {
-				args = append(args, window)
-			}
-
-			err = nv.Call("getcwd", &cwd, args...)
+		if fromTerminal {
+			// Rather than splitting the terminal, replace the file in an editor window.
+			var found bool
+			found, err = n.gotoEditorWindow()
 			if err != nil {
 				return
 			}
+			if found {
+				method = openByEdit
+			}
 		}
-		result = cwd + "/" + fpath
+
+		c, err := n.prepareOpen(method)
+		cmds = jump.OpenCmds{File: c.file, Dir: c.dir, Buffer: c.buffer}
+		return
+	}
+
+	ctx, cancel := n.statContext()
+	defer cancel()
+
+	opened, err := jump.OpenOrChangeTo(ctx, n, fpath, srchType, prepare)
+	if err != nil {
+		err = n.statError(err, fpath)
+		return
+	}
+	trace(n, "trace: SplitOrChangeTo: opened %s (%d)", fpath, opened)
+
+	// Showing a hidden buffer restores its last cursor position
+	wasOpen = opened != jump.OpenedFile
+	if opened != jump.OpenedInWindow {
+		err = n.finishOpen(method)
 	}
 	return
 }
 
-type SearchType int
+// parseSwitchbuf derives how to search for and open a file from the value
+// of the 'switchbuf' option, in the same way as quickfix commands like :cc do.
+// If `useLast` is true the file should be opened in the previous window.
+func parseSwitchbuf(switchbuf string) (srchType jump.SearchType, method string, useLast bool) {
+	srchType = jump.SearchNowhere
+	method = openByEdit
 
-const (
-	SearchOnlyInCurrentTab = iota
-	SearchInAllTabs
-)
+	flags := map[string]bool{}
+	for _, f := range strings.Split(switchbuf, ",") {
+		flags[strings.TrimSpace(f)] = true
+	}
 
-func (n Basejump) findWindow(fpath string, srchType SearchType) (win *nvim.Window, tabNr, winNr int, err error) {
-	nv := n.nvim()
+	if flags["usetab"] {
+		srchType = jump.SearchInAllTabs
+	} else if flags["useopen"] {
+		srchType = jump.SearchOnlyInCurrentTab
+	}
+
+	switch {
+	case flags["newtab"]:
+		method = openByTab
+	case flags["vsplit"]:
+		method = openByVsplit
+	case flags["split"]:
+		method = openBySplit
+	case flags["uselast"]:
+		useLast = true
+	}
+	return
+}
 
-	defer func() {
-		if win != nil {
-			trace(n, "trace: findWindow: `%s` found in tab %d window %d", fpath, tabNr, winNr)
+// openCmds are the commands used to open a target in a particular open mode.
+type openCmds struct {
+	// file opens a file
+	file string
+	// dir opens a directory
+	dir string
+	// empty opens an empty buffer
+	empty string
+	// buffer is a format string for the command that shows an existing
+	// buffer. It is formatted with the buffer number.
+	buffer string
+}
+
+// prepareOpen returns the commands used to open a target using the open mode `method`. For
+// the modes that open the target in an existing or a floating window, that window
+// is made current first.
+func (n Basejump) prepareOpen(method string) (cmds openCmds, err error) {
+	switch method {
+	case openByVsplit:
+		cmds = openCmds{"vsplit", "Vexplore", "vnew", "vertical sbuffer %d"}
+	case openByTab:
+		cmds = openCmds{"tabedit", "Texplore", "tabnew", "tab sbuffer %d"}
+	case openByEdit:
+		cmds = openCmds{"edit", "Explore", "enew", "buffer %d"}
+	case openByPreview:
+		// A terminal can't be opened in the preview window, so empty buffers are split instead.
+		cmds = openCmds{"pedit", "pedit", "new", "pedit #%d"}
+	case openByFloat:
+		cmds = openCmds{"edit", "Explore", "enew", "buffer %d"}
+		err = n.openFloat()
+	case openByAuto:
+		cmds, err = n.autoSplitCmds()
+	case openByReuse:
+		var found bool
+		found, err = n.gotoReuseWindow()
+		if found {
+			cmds = openCmds{"edit", "Explore", "enew", "buffer %d"}
 		} else {
-			trace(n, "trace: findWindow: `%s` not found in open windows", fpath)
+			// The reuse window is created by splitting.
+			cmds = openCmds{"split", "Hexplore", "new", "sbuffer %d"}
 		}
-	}()
-
-	nfpath, err := n.AbsPath(fpath)
-	if err == nil {
-		fpath = nfpath
+	default:
+		cmds = openCmds{"split", "Hexplore", "new", "sbuffer %d"}
 	}
+	return
+}
 
-	findInList := func(wins []nvim.Window) {
-		for _, cwin := range wins {
-			var buf nvim.Buffer
-			var bufFileName string
-
-			buf, err = nv.WindowBuffer(cwin)
-			if err != nil {
-				trace(n, "trace: findWindow: WindowBuffer(%d) failed: %v", cwin, err)
-				return
-			}
+// finishOpen performs the work needed after a target was opened using the
+// open mode `method`.
+func (n Basejump) finishOpen(method string) (err error) {
+	nv := n.nvim()
 
-			bufFileName, err = nv.BufferName(buf)
-			if err != nil {
-				trace(n, "trace: findWindow: BufferName failed: %v", err)
-				return
-			}
+	switch method {
+	case openByPreview:
+		// :pedit doesn't move to the preview window, but we need to be in it
+		// to move the cursor. OpenPathAtLineCol moves back afterwards.
+		err = nv.Command("wincmd P")
+	case openByReuse:
+		err = nv.Call("win_getid", &n.state.reuseWinID)
+		trace(n, "trace: finishOpen: reuse window is %d", n.state.reuseWinID)
+	}
+	return
+}
 
-			winNr, err = nv.WindowNumber(cwin)
-			if err != nil {
-				trace(n, "trace: findWindow: WindowNumber(%d) failed: %v", cwin, err)
-				return
-			}
+// autoSplitCmds returns the commands used to open a target by splitting the current window
+// in the direction that suits its shape. If g:basejump_auto_split_largest is set, the largest
+// window in the tab is made current and split instead. Since plain split commands are used the
+// new window is placed according to 'splitright' and 'splitbelow'.
+func (n Basejump) autoSplitCmds() (cmds openCmds, err error) {
+	nv := n.nvim()
 
-			bufFileName, err = n.AbsPathRelWindow(bufFileName, winNr)
-			if err != nil {
-				trace(n, "trace: findWindow: n.AbsPathRelWindow failed: %v", err)
-				return
-			}
+	c := n.config()
+	minWidth, minHeight := c.AutoMinWidth, c.AutoMinHeight
 
-			trace(n, "trace: findWindow: '%s' vs '%s'", path.Clean(bufFileName), path.Clean(fpath))
-			if path.Clean(bufFileName) == path.Clean(fpath) {
-				win = &cwin
-				return
-			}
+	if c.AutoSplitLargest {
+		err = n.gotoLargestWindow()
+		if err != nil {
+			return
 		}
+	}
 
+	var width, height int
+	err = nv.Call("winwidth", &width, 0)
+	if err != nil {
+		return
+	}
+	err = nv.Call("winheight", &height, 0)
+	if err != nil {
 		return
 	}
 
-	if srchType == SearchOnlyInCurrentTab {
-		trace(n, "trace: findWindow: searching for `%s` in current tab's windows", fpath)
-		var wins []nvim.Window
-		wins, err = nv.Windows()
-		if err != nil {
-			return
-		}
-		findInList(wins)
-	} else {
-		trace(n, "trace: findWindow: searching for `%s` in all tabs windows", fpath)
-		var tabs []nvim.Tabpage
-		tabs, err = nv.Tabpages()
-		if err != nil {
-			return
-		}
+	method, size := chooseSplit(width, height, minWidth, minHeight)
+	trace(n, "trace: autoSplitCmds: window is %dx%d. Using %s with size %d", width, height, method, size)
 
-		// Move the current tab to the first, so that if the window is in the
-		// current tabpage and another one, we prefer the local one
-		var curTab nvim.Tabpage
-		curTab, err = nv.CurrentTabpage()
-		if err != nil {
-			return
-		}
-		for i, tab := range tabs {
-			if tab == curTab && i > 0 {
-				t := tabs[0]
-				tabs[0] = tabs[i]
-				tabs[i] = t
-				break
-			}
-		}
+	count := ""
+	if size > 0 {
+		count = strconv.Itoa(size)
+	}
+	// A count before :sbuffer is a buffer number, not a size, so split and then change buffer.
+	if method == openByVsplit {
+		cmds = openCmds{count + "vsplit", "Vexplore", count + "vnew", count + "vsplit | buffer %d"}
+	} else {
+		cmds = openCmds{count + "split", "Hexplore", count + "new", count + "split | buffer %d"}
+	}
+	return
+}
 
-		for _, tab := range tabs {
-			var wins []nvim.Window
-			wins, err = nv.TabpageWindows(tab)
-			if err != nil {
-				return
-			}
+// gotoLargestWindow makes the largest non-floating window in the current tab the
+// current window.
+func (n Basejump) gotoLargestWindow() (err error) {
+	nv := n.nvim()
 
-			tabNr, err = nv.TabpageNumber(tab)
-			if err != nil {
-				return
-			}
+	var wins [][3]int
+	err = nv.Eval(`map(filter(getwininfo(), {_, w -> w.tabnr == tabpagenr() && win_gettype(w.winid) == ''}),`+
+		` {_, w -> [w.winid, w.width, w.height]})`, &wins)
+	if err != nil {
+		return
+	}
 
-			findInList(wins)
-			if err != nil || win != nil {
-				return
-			}
+	largest, area := 0, -1
+	for _, w := range wins {
+		if w[1]*w[2] > area {
+			largest, area = w[0], w[1]*w[2]
 		}
 	}
 
+	if largest != 0 {
+		err = nv.Call("win_gotoid", nil, largest)
+	}
 	return
 }
 
-// OpenOrChangeTo ensures the specified file is open in vim. If the path is found in a
-// window, that window is made current. If no window contains that path, it is split and
-// opened.
-func (n Basejump) OpenOrChangeTo(fpath, method string) (wasOpen bool, err error) {
-	nv := n.nvim()
+// chooseSplit decides how to split a window that is `width` columns wide and `height`
+// lines high so that both windows are at least `minWidth` wide and `minHeight` high.
+// It returns openBySplit or openByVsplit, and the size of the new window or 0 to split
+// the window evenly. If the window is too small to split evenly either way the new window
+// is given the minimum size in the direction that fits best.
+func chooseSplit(width, height, minWidth, minHeight int) (method string, size int) {
+	// One column is used by the separator between vertical splits, and
+	// one line by the status line of horizontal splits.
+	fitsV := (width-1)/2 >= minWidth
+	fitsH := (height-1)/2 >= minHeight
+
+	switch {
+	case fitsV && fitsH:
+		// Character cells are about twice as high as they are wide.
+		if width > 2*height {
+			return openByVsplit, 0
+		}
+		return openBySplit, 0
+	case fitsV:
+		return openByVsplit, 0
+	case fitsH:
+		return openBySplit, 0
+	}
 
-	//win, winNr, err := n.findWindow(fpath, SearchOnlyInCurrentTab)
-	win, tabNr, winNr, err := n.findWindow(fpath, SearchInAllTabs)
-	if err != nil {
-		return
+	if minWidth < 1 || minHeight < 1 || width*minHeight >= height*minWidth {
+		return openByVsplit, limit(minWidth, 1, width-2)
 	}
+	return openBySplit, limit(minHeight, 1, height-2)
+}
 
-	if win != nil {
-		// Change to this window
-		trace(n, "trace: SplitOrChangeTo: changing to tab")
-		nv.Command(fmt.Sprintf("%dtabnext", tabNr))
+func limit(v, low, high int) int {
+	if v > high {
+		v = high
+	}
+	if v < low {
+		v = low
+	}
+	return v
+}
 
-		trace(n, "trace: SplitOrChangeTo: changing to existing window")
-		err = nv.Command(fmt.Sprintf("%dwincmd w", winNr))
-		wasOpen = true
+// gotoReuseWindow makes the window that jumps are loaded into in the 'reuse'
+// open mode the current window. If there is no such window `found` is false.
+func (n Basejump) gotoReuseWindow() (found bool, err error) {
+	if n.state.reuseWinID == 0 {
 		return
 	}
 
-	// Not found. Split new window
-	// Seems like :split is not working from a script for directories for me
-	// (see https://superuser.com/questions/1243344/vim-wont-split-open-a-directory-from-python-but-it-works-interactively)
-	// so if it's a directory, use Hexplore instead.
-	isDir := false
-	var fi os.FileInfo
-	if fi, err = os.Stat(fpath); err == nil && fi.IsDir() {
-		isDir = true
-	}
+	var ok int
+	err = n.nvim().Call("win_gotoid", &ok, n.state.reuseWinID)
+	found = ok == 1
+	return
+}
+
+// openFloat opens a floating window centered in the editor and makes it the
+// current window. It initially shows the current buffer.
+func (n Basejump) openFloat() (err error) {
+	nv := n.nvim()
 
-	action := "splitting"
-	dirCmd := "Hexplore"
-	splitCmd := "split"
-	if method == openByTab {
-		action = "tabbing"
-		dirCmd = "Texplore"
-		splitCmd = "tabedit"
+	var columns, lines int
+	err = nv.Eval("&columns", &columns)
+	if err != nil {
+		return
+	}
+	err = nv.Eval("&lines", &lines)
+	if err != nil {
+		return
 	}
 
-	trace(n, "trace: SplitOrChangeTo: no window matches. %s %s.", action, fpath)
-	if isDir {
-		// If it's a directory, use :Hexplore instead.
-		err = nv.Command(fmt.Sprintf("%s %s", dirCmd, fpath))
-	} else {
-		err = nv.Command(fmt.Sprintf("%s %s", splitCmd, fpath))
+	width := columns * 4 / 5
+	height := (lines - 2) * 4 / 5
+	config := map[string]interface{}{
+		"relative": "editor",
+		"width":    width,
+		"height":   height,
+		"row":      (lines - height) / 2,
+		"col":      (columns - width) / 2,
+		"border":   "rounded",
 	}
 
+	var winID int
+	err = nv.Call("nvim_open_win", &winID, 0, true, config)
 	return
 }
 
@@ -431,97 +680,208 @@ func commandWhichExists(cmds []string) string {
 func (n Basejump) OpenRemoteUrl(url *url.URL, method string) error {
 	nv := n.nvim()
 
-	browsers := []string{"elinks", "w3m", "links", "lynx"}
-
-	err := nv.Var("basejump_browsers", browsers)
-	if err != nil {
-		n.Echom("basejump_browsers is not defined (%v). Defaulting to %s", err, browsers)
-	}
+	browsers := n.config().Browsers
 
 	b := commandWhichExists(browsers)
 	if b == "" {
 		return fmt.Errorf("error: no browser found. Tried %v", browsers)
 	}
 
-	action := "new"
-	if method == openByTab {
-		action = "tabnew"
+	cmds, err := n.prepareOpen(method)
+	if err != nil {
+		return err
 	}
-	err = nv.Command(action)
+	err = nv.Command(cmds.empty)
 	if err != nil {
 		return err
 	}
+	if method == openByReuse {
+		err = n.finishOpen(method)
+		if err != nil {
+			return err
+		}
+	}
 
-	err = nv.Call("termopen", nil, fmt.Sprintf("%s %s", b, url))
+	// The URL may come from the socket, so it is passed as an argument rather than
+	// through the shell where it could run commands.
+	err = nv.Call("termopen", nil, []string{b, url.String()})
 	return err
 }
 
-func (n Basejump) OpenPath(text, method string) error {
-	var path string
-	var line, col int
+// openCandidate opens the path or URL `c` using the open mode `method`. Relative paths are
+// relative to the cwd of the window `window`, or the current window if `window` is -1.
+func (n Basejump) openCandidate(c candidate, method string, window int) error {
+	t, err := n.resolveCandidate(c, n.absInWindow(window))
+	if err != nil {
+		return err
+	}
+
+	if t.URL != nil {
+		// Handle a remote URL specially
+		return n.OpenRemoteUrl(t.URL, method)
+	}
+
+	source, err := n.sourceName()
+	if err != nil {
+		return err
+	}
+
+	err = n.OpenPathAtLineCol(t.Path, t.Line, t.Col, method)
+	if err != nil {
+		return err
+	}
+
+	n.recordJump(c.String(), source, t.Path, t.Line, t.Col)
+	return nil
+}
+
+// absInWindow returns a function that makes paths absolute relative to the window
+// `window`, or the current window if `window` is -1.
+func (n Basejump) absInWindow(window int) func(fpath string) (string, error) {
+	return func(fpath string) (string, error) {
+		return jump.AbsPath(n, fpath, window)
+	}
+}
 
-	nv := n.nvim()
+// absRelDir returns a function that makes paths absolute relative to the directory `dir`.
+func absRelDir(dir string) func(fpath string) (string, error) {
+	return func(fpath string) (string, error) {
+		return jump.AbsPathRelDir(fpath, dir), nil
+	}
+}
 
-	trace(n, "trace: checking for URL")
-	// First, check for a URL
-	url, err := url.Parse(text)
-	if err == nil {
-		if url.Scheme == "file" {
-			path = url.Path
-		} else if url.Scheme == "http" || url.Scheme == "https" {
-			// Handle a remote URL specially
-			return n.OpenRemoteUrl(url, method)
-		}
+// resolve determines what the path or URL `text` refers to. Relative paths are made
+// absolute using `abs`.
+func (n Basejump) resolve(text string, abs func(fpath string) (string, error)) (t jump.Target, err error) {
+	t, how, err := jump.ParseTarget(text, abs)
+	if err != nil {
+		return
+	}
+	trace(n, "trace: resolve: resolved '%s' as %s", text, how)
+	if t.URL != nil {
+		return
 	}
 
-	if path == "" {
-		trace(n, "trace: parsing path")
-		path, line, col, err = n.ParsePath(text)
-		if err != nil {
-			return err
-		}
+	err = n.checkPath(t.Path)
+	return
+}
+
+// resolveCandidate determines what the candidate `c` refers to. A location that a resolver
+// gave is used as it is, with a relative path made absolute using `abs`. Text is resolved
+// like resolve does.
+func (n Basejump) resolveCandidate(c candidate, abs func(fpath string) (string, error)) (t jump.Target, err error) {
+	if c.target == nil {
+		return n.resolve(c.text, abs)
 	}
 
-	var openNonexistent int
-	nv.Var("basejump_open_nonexistent", &openNonexistent)
+	t = *c.target
+	t.Path, err = abs(t.Path)
+	if err != nil {
+		return
+	}
+	trace(n, "trace: resolve: resolved '%s' as %s", c, resolvedCustom)
+
+	err = n.checkPath(t.Path)
+	return
+}
 
+// checkPath returns an error if the path `path` doesn't exist and nonexistent files may
+// not be opened.
+func (n Basejump) checkPath(path string) (err error) {
+	// The path is checked even if nonexistent files may be opened, so that a path on a hung
+	// filesystem fails here rather than freezing nvim when it is opened.
 	trace(n, "trace: checking if path exists")
-	if openNonexistent == 0 && !pathExists(path) {
-		return fmt.Errorf("error: no such file '%s'", path)
+	exists, err := n.pathExists(path)
+	if err != nil {
+		return
+	}
+	if !n.config().OpenNonexistent && !exists {
+		err = fmt.Errorf("error: no such file '%s'", path)
+	}
+	return
+}
+
+// OpenPathAtLineCol ensures `path` is open in a window using the open mode `method`, and
+// moves the cursor to `line` and `col`. If g:basejump_target_server is set the jump is
+// made in that nvim instance instead of this one.
+func (n Basejump) OpenPathAtLineCol(path string, line, col int, method string) (err error) {
+	// Don't open anything if another jump was started while this one was being resolved.
+	err = n.context().Err()
+	if err != nil {
+		return
 	}
 
-	/*
-		trace(n, "trace: ensuring file is open or opening it")
-		var wasOpen bool
-		wasOpen, err = n.OpenOrChangeTo(path, method)
+	logger.Info("jump", "path", path, "line", line, "col", col, "method", method)
+
+	t, err := n.targetServer()
+	if err != nil {
+		return
+	}
+	if t != nil {
+		err = t.openPathAtLineCol(path, line, col, method)
 		if err != nil {
-			return err
+			return
 		}
+		return t.raise()
+	}
 
-		if col == 0 {
-			col = 1
-		}
-		if !wasOpen {
-			if line == 0 {
-				line = 1
-			}
-			err = n.JumpToLineAndCol(line, col)
-		} else {
-			if line != 0 {
-				err = n.JumpToLineAndCol(line, col)
-			}
-		}
+	return n.openPathAtLineCol(path, line, col, method)
+}
 
+func (n Basejump) openPathAtLineCol(path string, line, col int, method string) (err error) {
+	ev, err := n.fireEvent("BasejumpPre", jumpEvent{Path: path, Line: line, Col: col, Method: method})
+	if err != nil {
+		return
+	}
+	if ev.canceled() {
+		return errJumpCanceled
+	}
+	// The autocommands may change where the jump goes. A changed path may be relative or
+	// start with a tilde, and is checked like any other.
+	if ev.Path != path {
+		ev.Path, err = n.absInWindow(-1)(expandHome(ev.Path))
 		if err != nil {
-			return err
+			return
+		}
+		err = n.checkPath(ev.Path)
+		if err != nil {
+			return
 		}
-	*/
+	}
 
-	n.OpenPathAtLineCol(path, line, col, method)
-	return nil
+	ev.Cancel = nil
+	return n.jumpToPath(ev)
 }
 
-func (n Basejump) OpenPathAtLineCol(path string, line, col int, method string) (err error) {
+// jumpToPath ensures the path of the event `ev` is open in a window using its open mode,
+// and moves the cursor to its line and column. The User BasejumpPost autocommands are then
+// fired with the window and buffer the jump ended in, while that window is current.
+func (n Basejump) jumpToPath(ev jumpEvent) (err error) {
+	path, line, col, method := ev.Path, ev.Line, ev.Col, ev.Method
+
+	var fromWinID int
+	err = n.nvim().Call("win_getid", &fromWinID)
+	if err != nil {
+		return
+	}
+
+	from, err := n.tagFrom()
+	if err != nil {
+		return
+	}
+
+	keepFocus, err := n.terminalKeepsFocus()
+	if err != nil {
+		return
+	}
+	if keepFocus {
+		defer func() {
+			if err == nil {
+				err = n.nvim().Call("win_gotoid", nil, fromWinID)
+			}
+		}()
+	}
+
 	trace(n, "trace: ensuring file is open or opening it")
 	var wasOpen bool
 	wasOpen, err = n.OpenOrChangeTo(path, method)
@@ -529,6 +889,24 @@ func (n Basejump) OpenPathAtLineCol(path string, line, col int, method string) (
 		return
 	}
 
+	if method == openByPreview {
+		// Like :pedit, leave the cursor in the original window.
+		defer func() {
+			if err == nil {
+				err = n.nvim().Command("wincmd p")
+			}
+		}()
+	}
+
+	if from != nil {
+		// Deferred last so that it runs while the window the jump ended in is current
+		defer func() {
+			if err == nil {
+				err = n.pushTag(fromWinID, from, path, line)
+			}
+		}()
+	}
+
 	if col == 0 {
 		col = 1
 	}
@@ -542,96 +920,336 @@ func (n Basejump) OpenPathAtLineCol(path string, line, col int, method string) (
 			err = n.JumpToLineAndCol(line, col)
 		}
 	}
+	if err != nil {
+		return
+	}
 
+	// Before the deferred functions move the cursor to another window
+	err = n.nvim().Call("win_getid", &ev.WinID)
+	if err != nil {
+		return
+	}
+	err = n.nvim().Call("winbufnr", &ev.Bufnr, ev.WinID)
+	if err != nil {
+		return
+	}
+	_, err = n.fireEvent("BasejumpPost", ev)
 	return
 }
 
-func (n Basejump) OpenSelectedPath(method string) error {
-	trace(n, "trace: obtaining selected text")
+// Peek calls `open`, which performs a jump, and then returns the cursor to the window
+// and the position it was at before the jump. If the jump was made within the same window
+// the cursor is left at the target.
+func (n Basejump) Peek(open func() error) error {
+	nv := n.nvim()
 
-	text, err := n.SelectionText()
+	var winID int
+	err := nv.Call("win_getid", &winID)
 	if err != nil {
 		return err
 	}
 
-	if len(text) == 0 {
-		err = fmt.Errorf("selection is empty")
+	var view map[string]interface{}
+	err = nv.Call("winsaveview", &view)
+	if err != nil {
 		return err
 	}
 
-	// To expand tildes into home directories, we need a second expand
-	nv := n.nvim()
-	err = nv.Call("expand", &text, text)
+	err = open()
 	if err != nil {
 		return err
 	}
 
-	return n.OpenPath(text, method)
-}
+	var targetWinID int
+	err = nv.Call("win_getid", &targetWinID)
+	if err != nil || targetWinID == winID {
+		return err
+	}
 
-func (n Basejump) OpenPathUnderCursor(method string) error {
-	text, err := n.CurrentLineText()
+	trace(n, "trace: Peek: returning from window %d to window %d", targetWinID, winID)
+	err = nv.Call("win_gotoid", nil, winID)
 	if err != nil {
 		return err
 	}
-	_, col, err := n.Cursor()
+	return nv.Call("winrestview", nil, view)
+}
+
+// tagFrom returns the position of the cursor in the form used for the `from` item in
+// the tag stack, so that the position can be returned to after a jump. If
+// g:basejump_tagstack is zero the result is nil.
+func (n Basejump) tagFrom() (from []int, err error) {
+	if !n.config().Tagstack {
+		return
+	}
+
+	// [bufnr, lnum, col, off]
+	err = n.nvim().Eval("[bufnr('%')] + getcurpos()[1:3]", &from)
+	return
+}
+
+// pushTag pushes an item for a jump to `path` onto the tag stack of the window `winID` the
+// jump was made from, so that CTRL-T returns to the position `from`. The item names the
+// buffer of the current window, which is the one the jump ended in.
+func (n Basejump) pushTag(winID int, from []int, path string, line int) (err error) {
+	nv := n.nvim()
+
+	var bufnr int
+	err = nv.Call("bufnr", &bufnr, "%")
+	if err != nil {
+		return
+	}
+
+	tagname := filepath.Base(path)
+	if line > 0 {
+		tagname = fmt.Sprintf("%s:%d", tagname, line)
+	}
+
+	item := map[string]interface{}{
+		"bufnr":   bufnr,
+		"from":    from,
+		"tagname": tagname,
+	}
+
+	trace(n, "trace: pushTag: pushing %s from %v", tagname, from)
+	err = nv.Call("settagstack", nil, winID, map[string]interface{}{"items": []interface{}{item}}, "t")
+	return
+}
+
+// OpenSelectedPath opens the path in the selection `s` using the open mode `method`.
+func (n Basejump) OpenSelectedPath(s selectionState, method string) error {
+	trace(n, "trace: selected text is from line %d col %d to line %d col %d (mode '%s', selection=%s)",
+		s.Start[1], s.Start[2], s.End[1], s.End[2], s.Mode, s.Selection)
+
+	return n.openCandidates(s.texts(), method)
+}
+
+// OpenPathInLines treats each of the lines from `first` to `last` as a path or URL, and
+// opens the first one that exists using the open mode `method`.
+func (n Basejump) OpenPathInLines(first, last int, method string) error {
+	lines, err := n.Lines(first, last)
 	if err != nil {
 		return err
 	}
 
+	return n.openCandidates(lines, method)
+}
+
+// openCandidates opens the first of the paths or URLs `texts` that exists using the open mode
+// `method`. If none exist, the first is opened. The custom resolvers are applied to each text
+// first.
+func (n Basejump) openCandidates(texts []string, method string) (err error) {
+	candidates, err := n.selectionCandidates(texts)
+	if err != nil {
+		return
+	}
+
+	c, err := n.resolveTexts(candidates, -1, "")
+	if err != nil {
+		return
+	}
+
+	return n.openCandidate(c, method, -1)
+}
+
+// selectionCandidates returns the non-empty texts in `texts` with tildes expanded. These
+// are the candidates for the path to open.
+func (n Basejump) selectionCandidates(texts []string) (candidates []string, err error) {
 	nv := n.nvim()
-	var pathChars string
-	err = nv.Var("basejump_pathchars", &pathChars)
+	candidates = make([]string, 0, len(texts))
+	for _, text := range texts {
+		text = strings.TrimSpace(text)
+		if len(text) == 0 {
+			continue
+		}
+
+		// To expand tildes into home directories, we need a second expand
+		err = nv.Call("expand", &text, text)
+		if err != nil {
+			return
+		}
+		candidates = append(candidates, text)
+	}
+
+	if len(candidates) == 0 {
+		err = fmt.Errorf("selection is empty")
+	}
+	return
+}
+
+// chooseCandidate returns the candidate to open. When the selection spans several lines
+// each is a separate candidate, and the first one that refers to something that exists
+// is chosen. If none exist the first is. Relative paths are made absolute using `abs`.
+func (n Basejump) chooseCandidate(candidates []candidate, abs func(fpath string) (string, error)) (c candidate, err error) {
+	if len(candidates) > 1 {
+		var ok bool
+		c, ok, err = n.firstExistingCandidate(candidates, abs)
+		if err != nil || ok {
+			return
+		}
+		trace(n, "trace: chooseCandidate: none of the candidates exist")
+	}
+
+	return candidates[0], nil
+}
+
+// OpenPathUnderCursor opens the path under the cursor `cur` using the open mode `method`.
+func (n Basejump) OpenPathUnderCursor(cur cursorState, method string) error {
+	c, err := n.textAtCol(cur.Text, cur.Col, -1)
 	if err != nil {
-		pathChars = "-~/[a-z][A-Z].:[0-9]"
-		n.Echom("basejump_pathchars is not defined. Defaulting to %s", pathChars)
+		return err
 	}
 
-	text = matching(text, col-1, pathChars)
+	return n.openCandidate(c, method, -1)
+}
 
-	// To expand tildes into home directories, we need a second expand
-	err = nv.Call("expand", &text, text)
+// mousePos is the result of vim's getmousepos()
+type mousePos struct {
+	WinID  int `msgpack:"winid"`
+	Line   int `msgpack:"line"`
+	Column int `msgpack:"column"`
+}
+
+// mouseState is the position of the last mouse click, the number of the window it was in,
+// and the line of text it was on. Like cursorState it is captured when a vim function is
+// called.
+type mouseState struct {
+	Pos   mousePos `msgpack:"pos" eval:"getmousepos()"`
+	WinNr int      `msgpack:"winnr" eval:"win_id2win(getmousepos().winid)"`
+	Lines []string `msgpack:"lines" eval:"getbufline(winbufnr(getmousepos().winid), getmousepos().line)"`
+}
+
+// open is OpenPathUnderMouse for the mouse click `mouse`.
+func (mouse mouseState) open(n Basejump, method string) error {
+	return n.OpenPathUnderMouse(mouse, method)
+}
+
+// OpenPathUnderMouse is like OpenPathUnderCursor, but it finds the path at the position
+// of the mouse click `mouse` instead of the cursor. The click may be in a window that is
+// not the current window, and relative paths are relative to that window.
+func (n Basejump) OpenPathUnderMouse(mouse mouseState, method string) error {
+	pos := mouse.Pos
+	trace(n, "trace: OpenPathUnderMouse: mouse is at window %d line %d col %d", pos.WinID, pos.Line, pos.Column)
+
+	if pos.WinID == 0 || pos.Line == 0 || pos.Column == 0 {
+		return fmt.Errorf("the mouse is not over text in a window")
+	}
+	if mouse.WinNr == 0 {
+		return fmt.Errorf("the mouse is not over a window in the current tab")
+	}
+	if len(mouse.Lines) == 0 {
+		return fmt.Errorf("the mouse is not over text in a window")
+	}
+
+	c, err := n.textAtCol(mouse.Lines[0], pos.Column, mouse.WinNr)
 	if err != nil {
 		return err
 	}
 
-	return n.OpenPath(text, method)
+	return n.openCandidate(c, method, mouse.WinNr)
+}
+
+// PathInLine returns the longest path around the 1-based byte column `col` in `text`,
+// with a leading tilde expanded into the home directory.
+func (n Basejump) PathInLine(text string, col int) (path string, err error) {
+	nv := n.nvim()
+
+	path = matching(text, byteColToCharIndex(text, col), n.pathChars())
+
+	// To expand tildes into home directories, we need a second expand
+	err = nv.Call("expand", &path, path)
+	return
+}
+
+// pathChars returns the characters that may be part of a path, set by g:basejump_pathchars.
+func (n Basejump) pathChars() string {
+	return n.config().PathChars
 }
 
 // JumpToLineAndCol moves the cursor to the specified line and column in the
-// current buffer.
+// current buffer. The column is counted in the unit set by g:basejump_column_unit.
 func (n Basejump) JumpToLineAndCol(line, col int) (err error) {
-	nv := n.P.Nvim
+	nv := n.nvim()
+
+	byteCol, err := n.lineByteCol(line, col)
+	if err != nil {
+		return
+	}
+
 	// In order to store these jumps in the jump history
 	// we use the 'G' command first. This only stores the line
 	// number, though, with column 1 (instead of the correct column).
 	// We store a second jump by doing a forward search for any character,
-	// with the count of the column number i.e. 10/.
+	// with the count of the characters before the column i.e. 10/.
 	nv.Command(fmt.Sprintf("normal %dG", line))
-	if col > 1 {
-		nv.Command(fmt.Sprintf("normal %d/.", col-1))
+	if byteCol > 1 {
+		var text string
+		nv.Call("getline", &text, line)
+		if chars := byteColToCharIndex(text, byteCol); chars > 0 {
+			nv.Command(fmt.Sprintf("normal %d/.", chars))
+		}
 	}
 
 	// Just to make sure we didn't mess up
-	err = nv.Call("cursor", nil, line, col)
+	err = nv.Call("cursor", nil, line, byteCol)
 	return
 }
 
-func (n Basejump) OpenLineFromDiff(method string) error {
-	// When the diff code checks if a path exists, we want it to be
-	// relative to the current window, not to the basejump process.
-	pe := func(path string) bool {
-		npath, err := n.AbsPath(path)
+// lineByteCol converts the column `col` on line `line` of the current buffer from the unit
+// set by g:basejump_column_unit to a byte column.
+func (n Basejump) lineByteCol(line, col int) (byteCol int, err error) {
+	nv := n.nvim()
+
+	unit := n.config().ColumnUnit
+	if unit == ColumnBytes || col <= 1 {
+		return col, nil
+	}
+
+	var text string
+	err = nv.Call("getline", &text, line)
+	if err != nil {
+		return
+	}
+
+	var tabstop int
+	err = nv.Eval("&tabstop", &tabstop)
+	if err != nil {
+		return
+	}
+
+	byteCol = toByteCol(text, col, unit, tabstop)
+	trace(n, "trace: lineByteCol: %s column %d on line %d is byte column %d", unit, col, line, byteCol)
+	return
+}
+
+// lineFromDiff returns the file and line in the modified file that the diff line under
+// the cursor refers to.
+func (n Basejump) lineFromDiff() (path string, lineNo int, err error) {
+	// The paths in the diff are relative to the current window, not to the basejump process.
+	// The diff may name several candidate paths, which share one g:basejump_stat_timeout.
+	ctx, cancel := n.statContext()
+	defer cancel()
+
+	var statErr error
+	exists := func(path string) bool {
+		if statErr != nil {
+			return false
+		}
+		exists, err := jump.PathExistsContext(ctx, path)
 		if err != nil {
-			trace(n, "trace: OpenLineFromDiff: AbsPath(%s) failed: %v", path, err)
-			npath = path
+			statErr = n.statError(err, path)
 		}
-		trace(n, "trace: OpenLineFromDiff: npath is %s", npath)
+		return exists
+	}
 
-		return pathExists(npath)
+	path, lineNo, err = jump.LineFromDiff(n, exists)
+	if statErr != nil {
+		err = statErr
 	}
+	return
+}
 
-	path, lineNo, err := diff.CalcFileAndLine(n, pe)
+func (n Basejump) OpenLineFromDiff(method string) error {
+	path, lineNo, err := n.lineFromDiff()
 
 	trace(n, "trace: diff file: computed file '%s' line %d", path, lineNo)
 
@@ -639,7 +1257,22 @@ func (n Basejump) OpenLineFromDiff(method string) error {
 		return err
 	}
 
-	return n.OpenPathAtLineCol(path, lineNo, 1, method)
+	text, err := n.CurrentLineText()
+	if err != nil {
+		return err
+	}
+	source, err := n.sourceName()
+	if err != nil {
+		return err
+	}
+
+	err = n.OpenPathAtLineCol(path, lineNo, 1, method)
+	if err != nil {
+		return err
+	}
+
+	n.recordJump(text, source, path, lineNo, 1)
+	return nil
 }
 
 func expandCharRanges(chars string) string {
@@ -723,16 +1356,24 @@ func expandCharRanges(chars string) string {
 	return string(output)
 }
 
-// Starting at `index` in string `s`, move forwards and backwards
+// Starting at the character with index `index` in string `s`, move forwards and backwards
 // to find the longest string around `index` that contains only characters in
-// `chars`.
+// `chars`. Note that `index` counts characters, not bytes.
 func matching(s string, index int, chars string) string {
-	if index < 0 || index >= len(s) {
-		return ""
-	}
+	start, end := matchingSpan(s, index, chars)
+	return string([]rune(s)[start:end])
+}
 
+// matchingSpan is like matching, but returns the character indexes of the start
+// and the end (exclusive) of the string around `index`. If there is no such string
+// `start` and `end` are equal.
+func matchingSpan(s string, index int, chars string) (start, end int) {
 	srunes := []rune(s)
 
+	if index < 0 || index >= len(srunes) {
+		return
+	}
+
 	crunes := []rune(expandCharRanges(chars))
 	good := func(i int) bool {
 		for _, r := range crunes {
@@ -744,7 +1385,7 @@ func matching(s string, index int, chars string) string {
 	}
 
 	if !good(index) {
-		return ""
+		return
 	}
 
 	left := index
@@ -755,109 +1396,118 @@ func matching(s string, index int, chars string) string {
 	}
 
 	right := index
-	for ; right < len(s); right++ {
+	for ; right < len(srunes); right++ {
 		if !good(right) {
 			break
 		}
 	}
 
-	return string(srunes[left+1 : right])
-}
-
-func pathExists(path string) bool {
-	_, err := os.Stat(path)
-	return !os.IsNotExist(err)
-}
-
-var optLogPanic = flag.Bool("logpanic", false, "log panics to the file /tmp/basejump.panic")
-var optTrace = flag.Bool("trace", false, "trace execution to messages history")
-
-func logPanic() {
-	if v := recover(); v != nil {
-		f, err := os.Create("/tmp/basejump.panic")
-		if err != nil {
-			return
-		}
-		fmt.Fprintf(f, "%s\n", v)
-		fmt.Fprintf(f, "%s\n", debug.Stack())
-		f.Close()
-	}
+	return left + 1, right
 }
 
-func trace(n Basejump, fmt string, args ...interface{}) {
-	if *optTrace {
-		n.Echom(fmt, args...)
-	}
-}
+var optTrace = flag.Bool("trace", false, "trace execution to messages history, and log at the debug level")
+var optListen = flag.String("listen", "", "listen for requests to open paths on this unix socket. "+
+	"'default' uses "+server.DefaultPath())
 
 const (
-	openBySplit = "split"
-	openByTab   = "tab"
+	openBySplit   = "split"
+	openByVsplit  = "vsplit"
+	openByTab     = "tab"
+	openByEdit    = "edit"
+	openByFloat   = "float"
+	openByPreview = "preview"
+	openByReuse   = "reuse"
+	openByAuto    = "auto"
+	// openBySwitchbuf derives the open mode from the 'switchbuf' option
+	openBySwitchbuf = "switchbuf"
 )
 
-func main() {
+// openModes are the valid open modes.
+var openModes = []string{openBySplit, openByVsplit, openByTab, openByEdit, openByFloat,
+	openByPreview, openByReuse, openByAuto, openBySwitchbuf}
 
-	flag.Parse()
-
-	plugin.Main(func(p *plugin.Plugin) error {
-
-		a := Basejump{p}
-
-		openSelectedPath := func(args []string) (string, error) {
-			if *optLogPanic {
-				defer logPanic()
-			}
-
-			if len(args) == 0 {
-				args = append(args, openBySplit)
-			}
+func main() {
 
-			err := a.OpenSelectedPath(args[0])
+	if len(os.Args) > 1 {
+		if cmd, ok := subcommands[os.Args[1]]; ok {
+			err := cmd(os.Args[2:])
 			if err != nil {
-				a.Echom("error: %v", err)
+				fmt.Fprintf(os.Stderr, "basejump %s: %v\n", os.Args[1], err)
+				os.Exit(1)
 			}
-			// Returning an error here prints too much overdramatic red text
-			return "", nil
+			return
 		}
+	}
 
-		openPathUnderCursor := func(args []string) (string, error) {
-			if *optLogPanic {
-				defer logPanic()
-			}
+	flag.Parse()
+	openLog()
 
-			if len(args) == 0 {
-				args = append(args, openBySplit)
-			}
+	plugin.Main(func(p *plugin.Plugin) error {
 
-			err := a.OpenPathUnderCursor(args[0])
-			if err != nil {
-				a.Echom("error: %v", err)
-			}
-			// Returning an error here prints too much overdramatic red text
-			return "", nil
-		}
+		a := Basejump{P: p, state: &jumpState{}}
 
-		openLineFromDiff := func(args []string) (string, error) {
-			if *optLogPanic {
-				defer logPanic()
-			}
+		if *optListen != "" {
+			// nvim can't be called until the plugin is being served, so listen in the background
+			go a.Listen(*optListen)
+		}
 
-			if len(args) == 0 {
-				args = append(args, openBySplit)
+		// handler makes a vim function handler from a basejump function that
+		// takes an open mode. The handler has no result, so it is called
+		// asynchronously and nvim isn't blocked while the jump is made.
+		handler := func(f func(n Basejump, method string) error) func(args []string) {
+			return func(args []string) {
+				a.run(func(n Basejump) error {
+					// The mappings leave the open mode to the configuration
+					if len(args) == 0 || args[0] == "" {
+						return f(n, n.config().OpenMode)
+					}
+					return f(n, args[0])
+				})
 			}
+		}
 
-			err := a.OpenLineFromDiff(args[0])
-
-			if err != nil {
-				a.Echom("error: %v", err)
+		// peek makes a basejump function that takes an open mode return to where it started.
+		peek := func(f func(n Basejump, method string) error) func(n Basejump, method string) error {
+			return func(n Basejump, method string) error {
+				return n.Peek(func() error { return f(n, method) })
 			}
-			// Returning an error here prints too much overdramatic red text
-			return "", nil
 		}
 
-		p.HandleFunction(&plugin.FunctionOptions{Name: "OpenSelectedPath"}, openSelectedPath)
-		p.HandleFunction(&plugin.FunctionOptions{Name: "OpenPathUnderCursor"}, openPathUnderCursor)
-		p.HandleFunction(&plugin.FunctionOptions{Name: "OpenLineFromDiff"}, openLineFromDiff)
+		// The functions that open the text under the cursor, the selection or the mouse are
+		// passed its state as it was when they were called, since it may change before the
+		// jump is made.
+		p.HandleFunction(&plugin.FunctionOptions{Name: "OpenSelectedPath", Eval: "*"},
+			func(args []string, s *selectionState) { handler(s.open)(args) })
+		p.HandleFunction(&plugin.FunctionOptions{Name: "OpenPathUnderCursor", Eval: "*"},
+			func(args []string, cur *cursorState) { handler(cur.open)(args) })
+		p.HandleFunction(&plugin.FunctionOptions{Name: "OpenPathUnderMouse", Eval: "*"},
+			func(args []string, mouse *mouseState) { handler(mouse.open)(args) })
+		p.HandleFunction(&plugin.FunctionOptions{Name: "OpenLineFromDiff"}, handler(Basejump.OpenLineFromDiff))
+		p.HandleFunction(&plugin.FunctionOptions{Name: "BasejumpPreview", Eval: "*"},
+			func(args []string, cur *cursorState) { handler(cur.preview)(args) })
+		p.HandleFunction(&plugin.FunctionOptions{Name: "BasejumpPreviewOpen"}, handler(func(n Basejump, _ string) error { return n.PreviewOpen() }))
+		p.HandleFunction(&plugin.FunctionOptions{Name: "BasejumpHistory"}, handler(Basejump.ShowHistory))
+		p.HandleFunction(&plugin.FunctionOptions{Name: "BasejumpHistoryOpen"}, handler(func(n Basejump, _ string) error { return n.HistoryOpen() }))
+		p.HandleFunction(&plugin.FunctionOptions{Name: "PeekSelectedPath", Eval: "*"},
+			func(args []string, s *selectionState) { handler(peek(s.open))(args) })
+		p.HandleFunction(&plugin.FunctionOptions{Name: "PeekPathUnderCursor", Eval: "*"},
+			func(args []string, cur *cursorState) { handler(peek(cur.open))(args) })
+		p.HandleFunction(&plugin.FunctionOptions{Name: "PeekPathUnderMouse", Eval: "*"},
+			func(args []string, mouse *mouseState) { handler(peek(mouse.open))(args) })
+		p.HandleFunction(&plugin.FunctionOptions{Name: "PeekLineFromDiff"}, handler(peek(Basejump.OpenLineFromDiff)))
+		p.HandleFunction(&plugin.FunctionOptions{Name: "BasejumpComplete"}, a.Complete)
+		p.HandleFunction(&plugin.FunctionOptions{Name: "BasejumpResolve"}, a.ResolveUnderCursor)
+		p.HandleFunction(&plugin.FunctionOptions{Name: "BasejumpResolveSelection"},
+			func([]string) (resolution, error) { return a.ResolveSelection() })
+		p.HandleFunction(&plugin.FunctionOptions{Name: "BasejumpResolveDiff"},
+			func([]string) (resolution, error) { return a.ResolveDiff() })
+
+		p.HandleCommand(&plugin.CommandOptions{Name: "Basejump", NArgs: "*", Range: ".", Eval: "<range>",
+			Complete: "customlist,BasejumpComplete"}, a.command)
+		p.HandleCommand(&plugin.CommandOptions{Name: "BasejumpDiff", NArgs: "?",
+			Complete: "customlist,BasejumpComplete"}, a.diffCommand)
+		p.HandleCommand(&plugin.CommandOptions{Name: "BasejumpLog", NArgs: "?",
+			Complete: "customlist,BasejumpComplete"}, a.logCommand)
 		return nil
 	})
 }
@@ -2,7 +2,13 @@ package main
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/jeffwilliams/basejump/jump"
 )
 
 func TestExpandCharRanges(t *testing.T) {
@@ -53,6 +59,9 @@ func TestMatching(t *testing.T) {
 		{5, ":/path/to/file/file.c,", "/[a-z].", "/path/to/file/file.c"},
 		{5, "asd /path/to/file/file.c:20:50", "/[a-z][A-Z].:[0-9]", "/path/to/file/file.c:20:50"},
 		{5, "asd /path/to/file/file.c:20:50,", "/[a-z][A-Z].:[0-9]", "/path/to/file/file.c:20:50"},
+		{3, "日本 /path/to/file.c", "/[a-z].", "/path/to/file.c"},
+		{17, "日本 /path/to/file.c", "/[a-z].", "/path/to/file.c"},
+		{18, "日本 /path/to/file.c", "/[a-z].", ""},
 	}
 	for _, tc := range tests {
 		t.Run(fmt.Sprintf("%s[%d],%s", tc.input, tc.pos, tc.chars), func(t *testing.T) {
@@ -64,3 +73,235 @@ func TestMatching(t *testing.T) {
 		})
 	}
 }
+
+func TestSelectionSegments(t *testing.T) {
+	tests := []struct {
+		name             string
+		lines            []string
+		mode             string
+		startCol, endCol int
+		exclusive        bool
+		output           []string
+	}{
+		{"charwise", []string{"see /tmp/file.c here"}, "v", 5, 15, false, []string{"/tmp/file.c"}},
+		{"charwise exclusive", []string{"see /tmp/file.c here"}, "v", 5, 16, true, []string{"/tmp/file.c"}},
+		{"charwise multiline", []string{"x /tmp/", "file.c y"}, "v", 3, 6, false, []string{"/tmp/file.c"}},
+		{"charwise past end", []string{"file.c"}, "v", 1, 2147483647, false, []string{"file.c"}},
+		{"charwise multibyte", []string{"é/tmp/fé.c"}, "v", 3, 12, false, []string{"/tmp/fé.c"}},
+		{"charwise multibyte end", []string{"/tmp/fé x"}, "v", 1, 7, false, []string{"/tmp/fé"}},
+		{"linewise", []string{"a.c", "b.c"}, "V", 1, 2147483647, false, []string{"a.c", "b.c"}},
+		{"blockwise", []string{"1 a.c x", "2 b.c y"}, "\x16", 3, 5, false, []string{"a.c", "b.c"}},
+		{"blockwise exclusive", []string{"1 a.c x", "2 b.c y"}, "\x16", 3, 6, true, []string{"a.c", "b.c"}},
+		{"blockwise reversed", []string{"1 a.c x", "2 b.c y"}, "\x16", 5, 3, false, []string{"a.c", "b.c"}},
+		{"blockwise multibyte", []string{"é a.c x", "2 b.c y"}, "\x16", 4, 5, false, []string{"a.c", "b.c"}},
+		{"blockwise tab", []string{"\ta.c", "        b.c"}, "\x16", 2, 11, false, []string{"a.c", "b.c"}},
+		// 日本 takes four screen cells, so the block starts in the same screen column on both lines
+		{"blockwise wide", []string{"日本 a.c x", "1234 b.c y"}, "\x16", 8, 8, false, []string{"a.c", "b.c"}},
+		{"blockwise emoji", []string{"😀 a.c", "ab b.c"}, "\x16", 6, 6, false, []string{"a.c", "b.c"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := selectionSegments(tc.lines, tc.mode, tc.startCol, tc.endCol, tc.exclusive, 8)
+			if fmt.Sprintf("%q", r) != fmt.Sprintf("%q", tc.output) {
+				t.Fatalf("expected %q but got %q", tc.output, r)
+			}
+		})
+	}
+}
+
+func TestChooseSplit(t *testing.T) {
+	tests := []struct {
+		width, height, minWidth, minHeight int
+		method                             string
+		size                               int
+	}{
+		{240, 60, 80, 10, openByVsplit, 0},
+		{100, 60, 80, 10, openBySplit, 0},
+		{200, 120, 80, 10, openBySplit, 0},
+		{240, 15, 80, 10, openByVsplit, 0},
+		{120, 15, 80, 10, openByVsplit, 80},
+		{60, 15, 80, 10, openBySplit, 10},
+		{60, 5, 80, 10, openByVsplit, 58},
+		{100, 40, 80, 30, openBySplit, 30},
+	}
+	for _, tc := range tests {
+		t.Run(fmt.Sprintf("%dx%d", tc.width, tc.height), func(t *testing.T) {
+			method, size := chooseSplit(tc.width, tc.height, tc.minWidth, tc.minHeight)
+			if method != tc.method || size != tc.size {
+				t.Fatalf("expected %s %d but got %s %d", tc.method, tc.size, method, size)
+			}
+		})
+	}
+}
+
+func TestParseSwitchbuf(t *testing.T) {
+	tests := []struct {
+		switchbuf string
+		srchType  jump.SearchType
+		method    string
+		useLast   bool
+	}{
+		{"", jump.SearchNowhere, openByEdit, false},
+		{"useopen", jump.SearchOnlyInCurrentTab, openByEdit, false},
+		{"usetab", jump.SearchInAllTabs, openByEdit, false},
+		{"useopen,usetab", jump.SearchInAllTabs, openByEdit, false},
+		{"useopen,split", jump.SearchOnlyInCurrentTab, openBySplit, false},
+		{"vsplit", jump.SearchNowhere, openByVsplit, false},
+		{"usetab,newtab", jump.SearchInAllTabs, openByTab, false},
+		{"uselast", jump.SearchNowhere, openByEdit, true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.switchbuf, func(t *testing.T) {
+			srchType, method, useLast := parseSwitchbuf(tc.switchbuf)
+			if srchType != tc.srchType || method != tc.method || useLast != tc.useLast {
+				t.Fatalf("expected %d %s %v but got %d %s %v", tc.srchType, tc.method, tc.useLast, srchType, method, useLast)
+			}
+		})
+	}
+}
+
+func TestCompleteModes(t *testing.T) {
+	tests := []struct {
+		lead, cmdline string
+		modes         []string
+		first         bool
+	}{
+		{"", "Basejump ", openModes, true},
+		{"s", "Basejump s", []string{openBySplit, openBySwitchbuf}, true},
+		{"ta", "'<,'>Basejump ta", []string{openByTab}, true},
+		{"v", "BasejumpDiff v", []string{openByVsplit}, true},
+		{"", "Basejump tab ", nil, false},
+		{"ma", "Basejump tab ma", nil, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.cmdline, func(t *testing.T) {
+			modes, first := completeModes(tc.lead, tc.cmdline)
+			if !reflect.DeepEqual(modes, tc.modes) || first != tc.first {
+				t.Fatalf("expected %v %v but got %v %v", tc.modes, tc.first, modes, first)
+			}
+		})
+	}
+}
+
+func TestCompleteLevels(t *testing.T) {
+	tests := []struct {
+		lead, cmdline string
+		levels        []string
+	}{
+		{"", "BasejumpLog ", []string{"debug", "info", "warn", "error"}},
+		{"d", "BasejumpLog d", []string{"debug"}},
+		{"x", "BasejumpLog x", nil},
+		{"", "BasejumpLog debug ", nil},
+	}
+	for _, tc := range tests {
+		t.Run(tc.cmdline, func(t *testing.T) {
+			levels := completeLevels(tc.lead, tc.cmdline)
+			if !reflect.DeepEqual(levels, tc.levels) {
+				t.Fatalf("expected %v but got %v", tc.levels, levels)
+			}
+		})
+	}
+}
+
+func TestSplitModeArg(t *testing.T) {
+	tests := []struct {
+		args         []string
+		method, text string
+	}{
+		{nil, "", ""},
+		{[]string{"tab"}, openByTab, ""},
+		{[]string{"main.go:12"}, "", "main.go:12"},
+		{[]string{"vsplit", "my", "file.go"}, openByVsplit, "my file.go"},
+	}
+	for _, tc := range tests {
+		t.Run(strings.Join(tc.args, " "), func(t *testing.T) {
+			method, text := splitModeArg(tc.args)
+			if method != tc.method || text != tc.text {
+				t.Fatalf("expected %q %q but got %q %q", tc.method, tc.text, method, text)
+			}
+		})
+	}
+}
+
+func TestTagPushedToSourceWindow(t *testing.T) {
+	n := embeddedBasejump(t)
+	nv := n.nvim()
+
+	path := filepath.Join(t.TempDir(), "a.txt")
+	if err := os.WriteFile(path, []byte("x\ny\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var source int
+	err := nv.Call("win_getid", &source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = n.openPathAtLineCol(path, 2, 1, openBySplit)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var target int
+	err = nv.Call("win_getid", &target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target == source {
+		t.Fatalf("expected the jump to open a new window")
+	}
+
+	var length int
+	err = nv.Eval(fmt.Sprintf("gettagstack(%d).length", source), &length)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if length != 1 {
+		t.Fatalf("expected one item on the tag stack of the source window but got %d", length)
+	}
+}
+
+func TestOpenPathUnderCursorUsesCapturedCursor(t *testing.T) {
+	n := embeddedBasejump(t)
+	nv := n.nvim()
+
+	path := filepath.Join(t.TempDir(), "a.txt")
+	if err := os.WriteFile(path, []byte("x\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The cursor has moved off the path since the function was called
+	err := nv.Command("setlocal buftype=nofile | call setline(1, 'not a path')")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = n.OpenPathUnderCursor(cursorState{Text: "see " + path, Col: 6}, openByEdit)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var opened string
+	err = nv.Call("expand", &opened, "%:p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opened != path {
+		t.Fatalf("expected %s to be opened but got %s", path, opened)
+	}
+}
+
+func TestSelectionStateTexts(t *testing.T) {
+	s := selectionState{
+		Lines:     []string{"see /tmp/a.txt here"},
+		Start:     [4]int{0, 1, 5, 0},
+		End:       [4]int{0, 1, 14, 0},
+		Mode:      visualCharwise,
+		Selection: "inclusive",
+		Tabstop:   8,
+	}
+	texts := s.texts()
+	if !reflect.DeepEqual(texts, []string{"/tmp/a.txt"}) {
+		t.Fatalf("expected the selected path but got %q", texts)
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/neovim/go-client/nvim"
+	"github.com/neovim/go-client/nvim/plugin"
+)
+
+// embeddedBasejump returns a Basejump that makes jumps in an nvim started with --embed for
+// the test, with basejump's autoload functions on its runtimepath and the history disabled.
+// The test is skipped if nvim isn't installed.
+func embeddedBasejump(tb testing.TB) Basejump {
+	if _, err := exec.LookPath("nvim"); err != nil {
+		tb.Skip("nvim is not installed")
+	}
+
+	dir, err := os.Getwd()
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	v, err := nvim.NewChildProcess(nvim.ChildProcessArgs("--embed", "--headless", "--clean", "-n",
+		"--cmd", "let &runtimepath = $BASEJUMP_DIR . ',' . &runtimepath"),
+		nvim.ChildProcessEnv(append(os.Environ(), "BASEJUMP_DIR="+dir)))
+	if err != nil {
+		tb.Fatalf("starting nvim failed: %v", err)
+	}
+	tb.Cleanup(func() { v.Close() })
+
+	err = v.SetVar("basejump", map[string]interface{}{"history": false})
+	if err != nil {
+		tb.Fatalf("setting g:basejump failed: %v", err)
+	}
+	return Basejump{P: plugin.New(v), state: &jumpState{}}
+}
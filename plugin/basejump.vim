@@ -4,6 +4,11 @@ endif
 
 let g:loaded_basejump = 1
 
+" Each of the options below may also be set as a key of the dictionary
+" g:basejump, without the basejump_ prefix, which takes precedence. Options
+" for a filetype go in g:basejump.filetypes[filetype], and b:basejump sets
+" options for one buffer. See the README.
+
 " basejump_pathchars are the characters that are considered path of a valid
 " path. These are used by OpenPathUnderCursor to determine the extent of the
 " path under the cursor.
@@ -17,17 +22,122 @@ let g:basejump_open_nonexistent = 1
 let g:basejump_browsers = ['elinks','w3m','links','lynx']
 
 " Set this to the how you would like vim to open the file
-" 'tab' opens files in new tabs, 'split' opens files in a new split.
+" 'tab' opens files in new tabs, 'split' opens files in a new split,
+" 'vsplit' in a new vertical split, 'edit' in the current window,
+" 'float' in a centered floating window, 'preview' in the preview window,
+" 'reuse' in a single window that is reused by later jumps, and 'auto'
+" splits vertically or horizontally depending on the window's shape.
+" 'switchbuf' finds and opens files as the 'switchbuf' option directs, like
+" quickfix commands do.
 let g:basejump_openmode = 'split'
 
+" The minimum size of the windows created by splitting in the 'auto' open
+" mode.
+let g:basejump_auto_min_width = 80
+let g:basejump_auto_min_height = 10
+
+" If set to nonzero, the 'auto' open mode splits the largest window in the
+" tab instead of the current window.
+let g:basejump_auto_split_largest = 0
+
+" How column numbers in text like file.go:10:5 are counted. 'byte' counts
+" bytes like vim and most Go tools, 'char' counts characters, 'utf16' counts
+" UTF-16 code units like LSP, and 'display' counts screen cells with tabs
+" expanded like many compilers.
+let g:basejump_column_unit = 'byte'
+
+" If set to nonzero, jumps made from a terminal window are opened in an
+" editor window instead of splitting the terminal. This is the window chosen
+" with BasejumpSetEditorWindow(), or the most recently used non-terminal
+" window in the tab.
+let g:basejump_terminal_use_editor = 0
+
+" If set to nonzero along with g:basejump_terminal_use_editor, the cursor
+" stays in the terminal window after a jump.
+let g:basejump_terminal_keep_focus = 0
+
+" The number of lines shown above and below the target line by
+" BasejumpPreview().
+let g:basejump_preview_context = 5
+
+" If set to nonzero, each jump pushes an item onto the tag stack so that
+" CTRL-T returns to where the jump was made from.
+let g:basejump_tagstack = 1
+
+" If set to nonzero, jumps are recorded in a history file under
+" stdpath('data') that can be browsed with :BasejumpHistory.
+let g:basejump_history = 1
+
+" The number of jumps kept in the history file.
+let g:basejump_history_size = 1000
+
+" The nvim instance that jumps are made in. If empty, jumps are made in this
+" instance. '$NVIM' makes them in the nvim that this one runs in a terminal
+" of, 'discover' in another running nvim found by its socket, and any other
+" value is the address of the nvim to use.
+let g:basejump_target_server = ''
+
+" The number of milliseconds basejump waits for the filesystem when checking
+" whether a path exists, before giving up on the jump. This keeps a hung
+" network filesystem from blocking jumps.
+let g:basejump_stat_timeout = 2000
+
+" If set, basejump listens on this unix socket for paths to open, sent by
+" running 'basejump open'. 'default' uses a socket in $XDG_RUNTIME_DIR.
+" This must be set before the plugin is loaded.
+let g:basejump_listen = get(g:, 'basejump_listen', '')
+
+" Resolver commands, which find locations in text that basejump doesn't
+" understand by itself. Each is a string run by the shell, or a list of the
+" program and its arguments. They are sent the line and cursor column as JSON
+" and print a JSON list of candidate locations. See the README.
+let g:basejump_resolver_commands = []
+
+" The number of milliseconds basejump waits for the resolver commands.
+let g:basejump_resolver_timeout = 1000
+
+" The level of the least severe messages written to the log: 'debug',
+" 'info', 'warn' or 'error'. :BasejumpLog {level} changes it while running.
+" This must be set before basejump is first used.
+let g:basejump_log_level = get(g:, 'basejump_log_level', 'info')
+
+" The file basejump logs to. If empty, this is basejump.log in the directory
+" returned by stdpath('log'). This must be set before basejump is first used.
+let g:basejump_log_file = get(g:, 'basejump_log_file', '')
+
+" Each jump fires the autocommands User BasejumpPre, before the file is
+" opened, and User BasejumpPost, after the jump with the target window
+" current. While they run g:basejump_event describes the jump. BasejumpPre may
+" change its path, line and col keys, or set its cancel key to cancel the jump.
+
 let s:basejump_path = expand('<sfile>:p:h') . '/basejump' 
 
+" Return the option a:name, from g:basejump if it is set there, otherwise from
+" g:basejump_{name}.
+function! s:Option(name) abort
+  return get(get(g:, 'basejump', {}), a:name, get(g:, 'basejump_' . a:name))
+endfunction
+
 function! s:RequireBasejump(host) abort
   " 'basejump' is the binary created by compiling the program.
-  " If '-logpanic' is specified, panics in the binary are logged to
-  " /tmp/basejump.panic
-  return jobstart([s:basejump_path], {'rpc': v:true})
-  "return jobstart([s:basejump_path,'-logpanic'], {'rpc': v:true})
+  " If '-trace' is specified, messages logged at the debug level are also
+  " echoed to the messages history.
+  let logfile = s:Option('log_file')
+  if logfile == ''
+    let logfile = stdpath('log') . '/basejump.log'
+  endif
+  let args = [s:basejump_path, '-loglevel=' . s:Option('log_level'), '-log=' . expand(logfile)]
+  "call add(args, '-trace')
+  if s:Option('listen') != ''
+    call add(args, '-listen=' . s:Option('listen'))
+  endif
+  return jobstart(args, {'rpc': v:true})
+endfunction
+
+" Make the current window the window that jumps from terminal windows in
+" this tab are opened in.
+function! BasejumpSetEditorWindow()
+  let t:basejump_editor_win = win_getid()
 endfunction
 
 " This prevents a multi-line selection from triggering a call 
@@ -36,22 +146,71 @@ function! BasejumpOpenSelectedPathRange(action) range
   :call OpenSelectedPath(a:action)
 endfunction
 
+function! BasejumpPeekSelectedPathRange(action) range
+  :call PeekSelectedPath(a:action)
+endfunction
+
+command! BasejumpHistory call BasejumpHistory('')
+
 call remote#host#Register('basejump', 'x', function('s:RequireBasejump'))
 
-vmap <M-RightMouse> :call BasejumpOpenSelectedPathRange(g:basejump_openmode)<CR>
-nmap <M-RightMouse> :call OpenPathUnderCursor(g:basejump_openmode)<CR>
-nmap <M-S-RightMouse> :call OpenLineFromDiff(g:basejump_openmode)<CR>
-" M-S-RightMouse is overridden in URXVT. Uncomment the below binding to use 
-" Meta MiddleMouse instead.
-"nmap <M-MiddleMouse> :call OpenLineFromDiff(g:basejump_openmode)<CR>
-nmap <M-]> :call OpenPathUnderCursor(g:basejump_openmode)<CR>
-nmap <M-S-]> :call OpenLineFromDiff(g:basejump_openmode)<CR>
+" The plugin host is normally started by the first call to basejump. When
+" listening, start it right away so that 'basejump open' finds the socket.
+if s:Option('listen') != ''
+  if v:vim_did_enter
+    call remote#host#Require('basejump')
+  else
+    augroup basejump_listen
+      autocmd!
+      autocmd VimEnter * call remote#host#Require('basejump')
+    augroup END
+  endif
+endif
+
+nnoremap <silent> <Plug>(basejump-open) :<C-U>call OpenPathUnderCursor('')<CR>
+vnoremap <silent> <Plug>(basejump-open-selection) :call BasejumpOpenSelectedPathRange('')<CR>
+nnoremap <silent> <Plug>(basejump-open-mouse) :<C-U>call OpenPathUnderMouse('')<CR>
+nnoremap <silent> <Plug>(basejump-diff) :<C-U>call OpenLineFromDiff('')<CR>
+nnoremap <silent> <Plug>(basejump-peek) :<C-U>call PeekPathUnderCursor('')<CR>
+vnoremap <silent> <Plug>(basejump-peek-selection) :call BasejumpPeekSelectedPathRange('')<CR>
+nnoremap <silent> <Plug>(basejump-peek-mouse) :<C-U>call PeekPathUnderMouse('')<CR>
+nnoremap <silent> <Plug>(basejump-peek-diff) :<C-U>call PeekLineFromDiff('')<CR>
+nnoremap <silent> <Plug>(basejump-preview) :<C-U>call BasejumpPreview('')<CR>
+
+" Set g:basejump_no_mappings to nonzero before the plugin is loaded to map
+" the <Plug> mappings above yourself instead of using these defaults.
+if !get(g:, 'basejump_no_mappings', 0)
+  vmap <M-RightMouse> <Plug>(basejump-open-selection)
+  nmap <M-RightMouse> <Plug>(basejump-open-mouse)
+  nmap <M-S-RightMouse> <Plug>(basejump-diff)
+  " M-S-RightMouse is overridden in URXVT. Uncomment the below binding to use 
+  " Meta MiddleMouse instead.
+  "nmap <M-MiddleMouse> <Plug>(basejump-diff)
+  nmap <M-]> <Plug>(basejump-open)
+  nmap <M-S-]> <Plug>(basejump-diff)
+endif
 
 " The following lines are generated by running the program
 " command line flag --manifest basejump
 call remote#host#RegisterPlugin('basejump', '0', [
-\ {'type': 'function', 'name': 'OpenPathUnderCursor', 'sync': 1, 'opts': {}},
-\ {'type': 'function', 'name': 'OpenSelectedPath', 'sync': 1, 'opts': {}},
-\ {'type': 'function', 'name': 'OpenLineFromDiff', 'sync': 1, 'opts': {}},
+\ {'type': 'function', 'name': 'OpenPathUnderCursor', 'sync': 0, 'opts': {'eval': '{''text'': getline(''.''), ''col'': col(''.'')}'}},
+\ {'type': 'function', 'name': 'OpenSelectedPath', 'sync': 0, 'opts': {'eval': '{''lines'': getline(line("''<"), line("''>")), ''start'': getpos("''<"), ''end'': getpos("''>"), ''mode'': visualmode(), ''selection'': &selection, ''tabstop'': &tabstop}'}},
+\ {'type': 'function', 'name': 'OpenLineFromDiff', 'sync': 0, 'opts': {}},
+\ {'type': 'function', 'name': 'OpenPathUnderMouse', 'sync': 0, 'opts': {'eval': '{''pos'': getmousepos(), ''winnr'': win_id2win(getmousepos().winid), ''lines'': getbufline(winbufnr(getmousepos().winid), getmousepos().line)}'}},
+\ {'type': 'function', 'name': 'PeekSelectedPath', 'sync': 0, 'opts': {'eval': '{''lines'': getline(line("''<"), line("''>")), ''start'': getpos("''<"), ''end'': getpos("''>"), ''mode'': visualmode(), ''selection'': &selection, ''tabstop'': &tabstop}'}},
+\ {'type': 'function', 'name': 'PeekPathUnderCursor', 'sync': 0, 'opts': {'eval': '{''text'': getline(''.''), ''col'': col(''.'')}'}},
+\ {'type': 'function', 'name': 'PeekPathUnderMouse', 'sync': 0, 'opts': {'eval': '{''pos'': getmousepos(), ''winnr'': win_id2win(getmousepos().winid), ''lines'': getbufline(winbufnr(getmousepos().winid), getmousepos().line)}'}},
+\ {'type': 'function', 'name': 'PeekLineFromDiff', 'sync': 0, 'opts': {}},
+\ {'type': 'function', 'name': 'BasejumpPreview', 'sync': 0, 'opts': {'eval': '{''text'': getline(''.''), ''col'': col(''.'')}'}},
+\ {'type': 'function', 'name': 'BasejumpPreviewOpen', 'sync': 0, 'opts': {}},
+\ {'type': 'function', 'name': 'BasejumpHistory', 'sync': 0, 'opts': {}},
+\ {'type': 'function', 'name': 'BasejumpHistoryOpen', 'sync': 0, 'opts': {}},
+\ {'type': 'function', 'name': 'BasejumpComplete', 'sync': 1, 'opts': {}},
+\ {'type': 'function', 'name': 'BasejumpResolve', 'sync': 1, 'opts': {}},
+\ {'type': 'function', 'name': 'BasejumpResolveSelection', 'sync': 1, 'opts': {}},
+\ {'type': 'function', 'name': 'BasejumpResolveDiff', 'sync': 1, 'opts': {}},
+\ {'type': 'command', 'name': 'Basejump', 'sync': 0, 'opts': {'complete': 'customlist,BasejumpComplete', 'eval': '<range>', 'nargs': '*', 'range': ''}},
+\ {'type': 'command', 'name': 'BasejumpDiff', 'sync': 0, 'opts': {'complete': 'customlist,BasejumpComplete', 'nargs': '?'}},
+\ {'type': 'command', 'name': 'BasejumpLog', 'sync': 0, 'opts': {'complete': 'customlist,BasejumpComplete', 'nargs': '?'}},
 \ ])
 
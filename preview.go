@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"unicode/utf8"
+)
+
+// savedJump is a jump stored in a vim variable so that it can be made later,
+// for example from a preview window.
+type savedJump struct {
+	Path   string `msgpack:"path"`
+	Line   int    `msgpack:"line"`
+	Col    int    `msgpack:"col"`
+	Method string `msgpack:"method"`
+}
+
+// Preview resolves the path under the cursor `cur` like OpenPathUnderCursor does, but
+// instead of opening it, shows the lines around the target line in a floating window.
+// Pressing Enter in the floating window jumps to the target using the open mode `method`,
+// and pressing q or Escape closes it.
+func (n Basejump) Preview(cur cursorState, method string) error {
+	c, err := n.textAtCol(cur.Text, cur.Col, -1)
+	if err != nil {
+		return err
+	}
+
+	t, err := n.resolveCandidate(c, n.absInWindow(-1))
+	if err != nil {
+		return err
+	}
+
+	if t.URL != nil {
+		return fmt.Errorf("can't preview the URL %s", t.URL)
+	}
+
+	return n.showPreview(savedJump{t.Path, t.Line, t.Col, method})
+}
+
+// showPreview shows the lines around the target `info` in a floating window at the cursor.
+func (n Basejump) showPreview(info savedJump) error {
+	nv := n.nvim()
+
+	context := n.config().PreviewContext
+
+	line := info.Line
+	if line < 1 {
+		line = 1
+	}
+	first := line - context
+	if first < 1 {
+		first = 1
+	}
+
+	lines, err := readLines(info.Path, first, line+context)
+	if err != nil {
+		return err
+	}
+	if len(lines) == 0 {
+		return fmt.Errorf("%s has no line %d", info.Path, line)
+	}
+	if line-first >= len(lines) {
+		line = first + len(lines) - 1
+	}
+
+	var bufnr int
+	err = nv.Call("nvim_create_buf", &bufnr, false, true)
+	if err != nil {
+		return err
+	}
+
+	err = nv.Call("nvim_buf_set_lines", nil, bufnr, 0, -1, true, lines)
+	if err != nil {
+		return err
+	}
+
+	err = nv.Call("setbufvar", nil, bufnr, "basejump_preview", info)
+	if err != nil {
+		return err
+	}
+	err = nv.Call("setbufvar", nil, bufnr, "&bufhidden", "wipe")
+	if err != nil {
+		return err
+	}
+
+	opts := map[string]bool{"silent": true, "nowait": true}
+	for lhs, rhs := range map[string]string{
+		"<CR>":  "<Cmd>call BasejumpPreviewOpen()<CR>",
+		"q":     "<Cmd>close<CR>",
+		"<Esc>": "<Cmd>close<CR>",
+	} {
+		err = nv.Call("nvim_buf_set_keymap", nil, bufnr, "n", lhs, rhs, opts)
+		if err != nil {
+			return err
+		}
+	}
+
+	var columns int
+	err = nv.Eval("&columns", &columns)
+	if err != nil {
+		return err
+	}
+
+	width := 0
+	for _, l := range lines {
+		if w := utf8.RuneCountInString(l); w > width {
+			width = w
+		}
+	}
+	width = limit(width, 20, columns-4)
+
+	config := map[string]interface{}{
+		"relative": "cursor",
+		"row":      1,
+		"col":      0,
+		"width":    width,
+		"height":   len(lines),
+		"border":   "rounded",
+		"style":    "minimal",
+	}
+
+	var winID int
+	err = nv.Call("nvim_open_win", &winID, bufnr, true, config)
+	if err != nil {
+		return err
+	}
+
+	err = nv.Call("nvim_win_set_cursor", nil, winID, []int{line - first + 1, 0})
+	if err != nil {
+		return err
+	}
+
+	var ns int
+	err = nv.Call("nvim_buf_add_highlight", &ns, bufnr, -1, "CursorLine", line-first, 0, -1)
+	if err != nil {
+		return err
+	}
+
+	// Detect the filetype from the name of the file so the preview is highlighted like it.
+	var filetype string
+	err = nv.ExecLua("return vim.filetype.match({ filename = ... }) or ''", &filetype, info.Path)
+	if err != nil {
+		trace(n, "trace: showPreview: filetype detection failed: %v", err)
+		return nil
+	}
+	return nv.Call("setbufvar", nil, bufnr, "&filetype", filetype)
+}
+
+// PreviewOpen turns the preview in the current floating window into a jump to the
+// previewed target.
+func (n Basejump) PreviewOpen() error {
+	nv := n.nvim()
+
+	var info savedJump
+	err := nv.Eval("b:basejump_preview", &info)
+	if err != nil {
+		return fmt.Errorf("not in a basejump preview window")
+	}
+
+	err = nv.Command("close")
+	if err != nil {
+		return err
+	}
+
+	err = n.OpenPathAtLineCol(info.Path, info.Line, info.Col, info.Method)
+	if err != nil {
+		return err
+	}
+
+	n.recordJump(info.Path, "[basejump preview]", info.Path, info.Line, info.Col)
+	return nil
+}
+
+// readLines returns the lines numbered from `first` to `last` (starting at 1) of the file `path`.
+func readLines(path string, first, last int) (lines []string, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1024*1024)
+	for i := 1; i <= last && scanner.Scan(); i++ {
+		if i >= first {
+			lines = append(lines, scanner.Text())
+		}
+	}
+	err = scanner.Err()
+	return
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file.txt")
+	if err := os.WriteFile(path, []byte("1\n2\n3\n4\n5\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		first, last int
+		output      []string
+	}{
+		{1, 2, []string{"1", "2"}},
+		{2, 4, []string{"2", "3", "4"}},
+		{4, 10, []string{"4", "5"}},
+		{7, 10, nil},
+	}
+	for _, tc := range tests {
+		t.Run(fmt.Sprintf("%d-%d", tc.first, tc.last), func(t *testing.T) {
+			r, err := readLines(path, tc.first, tc.last)
+			if err != nil {
+				t.Fatalf("got error: %v", err)
+			}
+			if fmt.Sprintf("%q", r) != fmt.Sprintf("%q", tc.output) {
+				t.Fatalf("expected %q but got %q", tc.output, r)
+			}
+		})
+	}
+
+	_, err := readLines(filepath.Join(t.TempDir(), "missing"), 1, 2)
+	if err == nil {
+		t.Fatalf("expected an error for a missing file")
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/jeffwilliams/basejump/jump"
+)
+
+// resolution describes what text refers to, without jumping to it. It is printed as JSON
+// by `basejump resolve` and returned as a dictionary by BasejumpResolve().
+type resolution struct {
+	// Text is the path or URL that was resolved
+	Text string `json:"text" msgpack:"text"`
+	// Start and End are the 1-based byte columns of the first byte of Text in the
+	// line it was found in and of the byte after it, or 0 if it wasn't found in a line.
+	Start int    `json:"start" msgpack:"start"`
+	End   int    `json:"end" msgpack:"end"`
+	Path  string `json:"path,omitempty" msgpack:"path"`
+	Line  int    `json:"line,omitempty" msgpack:"line"`
+	Col   int    `json:"col,omitempty" msgpack:"col"`
+	URL   string `json:"url,omitempty" msgpack:"url"`
+	// ResolvedAs describes how Text was resolved: as a url, file url, absolute path,
+	// relative path, diff or by a custom resolver
+	ResolvedAs string `json:"resolved_as" msgpack:"resolved_as"`
+	Exists     bool   `json:"exists" msgpack:"exists"`
+	// Tab and Window are the numbers of a window that already shows Path, which a jump
+	// would change to instead of opening the file. They are 0 if there is none.
+	Tab    int `json:"tab,omitempty" msgpack:"tab"`
+	Window int `json:"window,omitempty" msgpack:"window"`
+	// Error describes why the text couldn't be resolved
+	Error string `json:"error,omitempty" msgpack:"error"`
+}
+
+// resolvedDiff is how a line in a diff is resolved
+const resolvedDiff = "diff"
+
+// resolveLine finds the path or URL at the 1-based byte column `col` in `text` and
+// resolves it. Relative paths are made absolute using `abs`.
+func resolveLine(text string, col int, pathChars string, abs func(fpath string) (string, error)) (r resolution, err error) {
+	start, end := matchingSpan(text, byteColToCharIndex(text, col), pathChars)
+	if start == end {
+		err = fmt.Errorf("no path at column %d", col)
+		return
+	}
+
+	runes := []rune(text)
+	r.Text = string(runes[start:end])
+	r.Start = len(string(runes[:start])) + 1
+	r.End = r.Start + len(r.Text)
+
+	err = resolveText(&r, expandHome(r.Text), abs)
+	return
+}
+
+// resolveText resolves `text` into `r`.
+func resolveText(r *resolution, text string, abs func(fpath string) (string, error)) error {
+	t, how, err := jump.ParseTarget(text, abs)
+	if err != nil {
+		return err
+	}
+
+	r.ResolvedAs = how
+	if t.URL != nil {
+		r.URL = t.URL.String()
+		return nil
+	}
+	r.Path, r.Line, r.Col = t.Path, t.Line, t.Col
+	return nil
+}
+
+// resolveChosen resolves the candidate `c` into `r`. Candidates that a resolver found are
+// resolved as custom.
+func resolveChosen(r *resolution, c candidate, abs func(fpath string) (string, error)) (err error) {
+	r.Text = c.String()
+	if c.target == nil {
+		err = resolveText(r, c.text, abs)
+	} else {
+		r.Line, r.Col = c.target.Line, c.target.Col
+		r.Path, err = abs(c.target.Path)
+	}
+	if c.custom {
+		r.ResolvedAs = resolvedCustom
+	}
+	return
+}
+
+// ResolveUnderCursor describes what the path under the cursor refers to, or what
+// `args[0]` refers to if it is given, like OpenPathUnderCursor and :Basejump would.
+func (n Basejump) ResolveUnderCursor(args []string) (resolution, error) {
+	return n.resolution(func() (r resolution, err error) {
+		abs := n.absInWindow(-1)
+
+		if len(args) > 0 && args[0] != "" {
+			var c candidate
+			c, err = n.resolveTexts([]string{expandHome(args[0])}, -1, "")
+			if err != nil {
+				return
+			}
+			err = resolveChosen(&r, c, abs)
+			return
+		}
+
+		text, err := n.CurrentLineText()
+		if err != nil {
+			return
+		}
+		_, col, err := n.Cursor()
+		if err != nil {
+			return
+		}
+
+		candidates, err := n.customCandidates(text, col, -1)
+		if err != nil {
+			return
+		}
+		if len(candidates) > 0 {
+			var c candidate
+			c, err = n.chooseCandidate(candidates, abs)
+			if err != nil {
+				return
+			}
+			err = resolveChosen(&r, c, abs)
+			return
+		}
+
+		return resolveLine(text, col, n.pathChars(), abs)
+	})
+}
+
+// ResolveSelection describes what the selected path refers to, like OpenSelectedPath
+// would.
+func (n Basejump) ResolveSelection() (resolution, error) {
+	return n.resolution(func() (r resolution, err error) {
+		s, err := n.selectionState()
+		if err != nil {
+			return
+		}
+		texts := s.texts()
+
+		candidates, err := n.selectionCandidates(texts)
+		if err != nil {
+			return
+		}
+
+		c, err := n.resolveTexts(candidates, -1, "")
+		if err != nil {
+			return
+		}
+
+		err = resolveChosen(&r, c, n.absInWindow(-1))
+		return
+	})
+}
+
+// ResolveDiff describes the file and line that the diff line under the cursor refers
+// to, like OpenLineFromDiff would.
+func (n Basejump) ResolveDiff() (resolution, error) {
+	return n.resolution(func() (r resolution, err error) {
+		r.ResolvedAs = resolvedDiff
+		r.Text, err = n.CurrentLineText()
+		if err != nil {
+			return
+		}
+
+		r.Path, r.Line, err = n.lineFromDiff()
+		return
+	})
+}
+
+// resolution calls `resolve` and fills in whether the path exists and is shown in a
+// window. Errors are reported in the result rather than returned, so that scripts can
+// handle them.
+func (n Basejump) resolution(resolve func() (resolution, error)) (r resolution, _ error) {
+	defer logPanic()
+
+	r, err := resolve()
+	if err == nil && r.Path != "" {
+		r.Exists, err = n.pathExists(r.Path)
+	}
+	if err == nil && r.Exists {
+		var win *jump.Window
+		ctx, cancel := n.statContext()
+		win, err = jump.FindWindow(ctx, n, r.Path, jump.SearchInAllTabs)
+		cancel()
+		err = n.statError(err, r.Path)
+		if win != nil {
+			r.Tab, r.Window = win.Tab, win.Number
+		}
+	}
+
+	if err != nil {
+		r.Error = err.Error()
+	}
+	return r, nil
+}
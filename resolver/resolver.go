@@ -0,0 +1,118 @@
+// Package resolver runs external resolver commands, which find locations in text that
+// basejump doesn't understand by itself. A resolver command is sent a Request as JSON
+// on its standard input, and writes a JSON array of candidate locations to its standard
+// output. Each candidate is either text for basejump to parse, like "file.go:12:3", or
+// an object with the keys "path", "line" and "col".
+package resolver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Request describes where in the editor a location is being looked for.
+type Request struct {
+	// Line is the text of the line
+	Line string `json:"line"`
+	// Col is the 1-based byte column of the cursor in Line
+	Col int `json:"col"`
+	// Cwd is the working directory of the window. Commands are run in it.
+	Cwd      string `json:"cwd"`
+	Buffer   string `json:"buffer"`
+	Filetype string `json:"filetype"`
+}
+
+// Candidate is a location found by a resolver command. Either Text or Path is set.
+type Candidate struct {
+	Text string `json:"text,omitempty"`
+	Path string `json:"path,omitempty"`
+	Line int    `json:"line,omitempty"`
+	Col  int    `json:"col,omitempty"`
+}
+
+// UnmarshalJSON decodes a candidate from either a string, which becomes Text, or an object.
+func (c *Candidate) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		return json.Unmarshal(data, &c.Text)
+	}
+
+	type candidate Candidate
+	return json.Unmarshal(data, (*candidate)(c))
+}
+
+// waitDelay is how long Run waits for a killed command's output to be closed, in case
+// it left children running that hold it open.
+const waitDelay = 100 * time.Millisecond
+
+// Run runs the resolver command `argv` with the request `req` and returns the candidates it
+// found. The command is killed when `ctx` is done.
+func Run(ctx context.Context, argv []string, req Request) (candidates []Candidate, err error) {
+	if len(argv) == 0 {
+		err = fmt.Errorf("empty resolver command")
+		return
+	}
+
+	input, err := json.Marshal(req)
+	if err != nil {
+		return
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Dir = req.Cwd
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.WaitDelay = waitDelay
+
+	err = cmd.Run()
+	if ctx.Err() != nil {
+		err = ctx.Err()
+		return
+	}
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			err = fmt.Errorf("%v: %s", err, msg)
+		}
+		return
+	}
+
+	if len(bytes.TrimSpace(stdout.Bytes())) == 0 {
+		return
+	}
+	err = json.Unmarshal(stdout.Bytes(), &candidates)
+	if err != nil {
+		err = fmt.Errorf("invalid output: %v", err)
+	}
+	return
+}
+
+// Result is the outcome of running one resolver command.
+type Result struct {
+	Candidates []Candidate
+	Err        error
+}
+
+// RunAll runs each of the resolver commands `commands` in parallel like Run, and returns
+// their results in the same order.
+func RunAll(ctx context.Context, commands [][]string, req Request) []Result {
+	results := make([]Result, len(commands))
+
+	var wg sync.WaitGroup
+	for i, argv := range commands {
+		wg.Add(1)
+		go func(i int, argv []string) {
+			defer wg.Done()
+			results[i].Candidates, results[i].Err = Run(ctx, argv, req)
+		}(i, argv)
+	}
+	wg.Wait()
+
+	return results
+}
@@ -0,0 +1,119 @@
+package resolver
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sh(script string) []string {
+	return []string{"sh", "-c", script}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	req := Request{Line: "see svc://billing/handler.go:12", Col: 5, Cwd: dir, Buffer: "notes.md", Filetype: "markdown"}
+
+	tests := []struct {
+		name     string
+		argv     []string
+		expected []Candidate
+		err      string
+	}{
+		{
+			name:     "objects and strings",
+			argv:     sh(`cat > /dev/null; echo '[{"path": "/src/a.go", "line": 12, "col": 3}, "b.go:4"]'`),
+			expected: []Candidate{{Path: "/src/a.go", Line: 12, Col: 3}, {Text: "b.go:4"}},
+		},
+		{
+			name:     "reads request",
+			argv:     sh(`sed -n 's/.*"filetype":"\([a-z]*\)".*/["\1"]/p'`),
+			expected: []Candidate{{Text: "markdown"}},
+		},
+		{
+			name:     "runs in cwd",
+			argv:     sh(`printf '["%s"]' "$(pwd)"`),
+			expected: []Candidate{{Text: dir}},
+		},
+		{
+			name: "no output",
+			argv: sh(`true`),
+		},
+		{
+			name: "empty list",
+			argv: sh(`echo '[]'`),
+		},
+		{
+			name: "fails",
+			argv: sh(`echo 'no index' >&2; exit 1`),
+			err:  "no index",
+		},
+		{
+			name: "invalid output",
+			argv: sh(`echo 'file.go:12'`),
+			err:  "invalid output",
+		},
+		{
+			name: "empty command",
+			err:  "empty",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			candidates, err := Run(context.Background(), tc.argv, req)
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("expected an error containing '%s' but got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("got error: %v", err)
+			}
+			if len(candidates) != 0 || len(tc.expected) != 0 {
+				if !reflect.DeepEqual(candidates, tc.expected) {
+					t.Fatalf("expected %+v but got %+v", tc.expected, candidates)
+				}
+			}
+		})
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := Run(ctx, sh(`sleep 5`), Request{})
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected the deadline to be exceeded but got %v", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("Run took %v to time out", d)
+	}
+}
+
+func TestRunAll(t *testing.T) {
+	commands := [][]string{
+		sh(`sleep 0.1; echo '["first"]'`),
+		sh(`exit 2`),
+		sh(`echo '["third"]'`),
+	}
+
+	results := RunAll(context.Background(), commands, Request{})
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results but got %d", len(results))
+	}
+	if results[0].Err != nil || !reflect.DeepEqual(results[0].Candidates, []Candidate{{Text: "first"}}) {
+		t.Fatalf("unexpected first result %+v", results[0])
+	}
+	if results[1].Err == nil {
+		t.Fatalf("expected the second command to fail")
+	}
+	if results[2].Err != nil || !reflect.DeepEqual(results[2].Candidates, []Candidate{{Text: "third"}}) {
+		t.Fatalf("unexpected third result %+v", results[2])
+	}
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jeffwilliams/basejump/jump"
+	"github.com/jeffwilliams/basejump/resolver"
+)
+
+// resolverCandidate is a location found in a line by a custom resolver registered with
+// basejump#register_resolver().
+type resolverCandidate struct {
+	Resolver string `msgpack:"resolver"`
+	// Text is set if the resolver returned text for basejump to parse
+	Text string `msgpack:"text"`
+	Path string `msgpack:"path"`
+	Line int    `msgpack:"line"`
+	Col  int    `msgpack:"col"`
+}
+
+// resolvedCustom is how text found by a custom resolver is resolved
+const resolvedCustom = "resolver"
+
+// candidate is a path or URL that a jump may go to. It is either text to parse, or a
+// location that a resolver gave as a path, line and column.
+type candidate struct {
+	text string
+	// target is set if a resolver gave a location. Its path may be relative.
+	target *jump.Target
+	// custom is true if a resolver found the candidate
+	custom bool
+}
+
+// String returns the candidate as text, for messages and the history.
+func (c candidate) String() string {
+	if c.target == nil {
+		return c.text
+	}
+
+	t := c.target
+	switch {
+	case t.Line > 0 && t.Col > 0:
+		return fmt.Sprintf("%s:%d:%d", t.Path, t.Line, t.Col)
+	case t.Line > 0:
+		return fmt.Sprintf("%s:%d", t.Path, t.Line)
+	}
+	return t.Path
+}
+
+// candidate returns the location the resolver found, or false if it found none. A
+// location given as a path is used as it is rather than parsed, so the path may contain
+// any character.
+func (c resolverCandidate) candidate() (cand candidate, ok bool) {
+	switch {
+	case c.Text != "":
+		return candidate{text: expandHome(c.Text), custom: true}, true
+	case c.Path != "":
+		t := &jump.Target{Path: expandHome(c.Path), Line: c.Line}
+		if c.Line > 0 {
+			// A column is only meaningful within a line
+			t.Col = c.Col
+		}
+		return candidate{target: t, custom: true}, true
+	}
+	return
+}
+
+// customCandidates calls the custom resolvers and then the resolver commands with the line
+// `text` and the 1-based byte column `col`, and returns the candidates they found.
+// The line is in the window `window`, or the current window if `window` is -1.
+func (n Basejump) customCandidates(text string, col, window int) (candidates []candidate, err error) {
+	r := n.startResolvers(window, "")
+	defer r.stop()
+
+	return r.candidates(text, col)
+}
+
+// resolverRun calls the custom resolvers with the texts looked at by one jump. The resolver
+// commands share one resolver_timeout for all the texts, and a command that fails is
+// reported once and not run again.
+type resolverRun struct {
+	n Basejump
+	// window is the window the texts are in, or -1 for the current window
+	window int
+	// dir is the directory the resolver commands are run in, if it isn't the window's
+	dir      string
+	commands [][]string
+	failed   []bool
+	// req holds the parts of the requests to the commands that are the same for every
+	// text. It is filled in when the commands are first run.
+	req *resolver.Request
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// startResolvers starts a resolverRun for texts in the window `window`, or the current
+// window if `window` is -1. The resolver commands are run in the directory `dir`, or the
+// window's directory if `dir` is "". Its stop method must be called when it is no longer
+// needed.
+func (n Basejump) startResolvers(window int, dir string) *resolverRun {
+	c := n.config()
+	ctx, cancel := context.WithTimeout(n.context(), time.Duration(c.ResolverTimeout)*time.Millisecond)
+	return &resolverRun{
+		n:        n,
+		window:   window,
+		dir:      dir,
+		commands: c.ResolverCommands,
+		failed:   make([]bool, len(c.ResolverCommands)),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+func (r *resolverRun) stop() {
+	r.cancel()
+}
+
+// candidates calls the custom resolvers and then the resolver commands with the line
+// `text` and the 1-based byte column `col`, and returns the candidates they found.
+func (r *resolverRun) candidates(text string, col int) (candidates []candidate, err error) {
+	n := r.n
+
+	var found []resolverCandidate
+	err = n.nvim().Call("basejump#run_resolvers", &found, text, col)
+	if err != nil {
+		return
+	}
+
+	commandFound, err := r.runCommands(text, col)
+	if err != nil {
+		return
+	}
+	found = append(found, commandFound...)
+
+	for _, c := range found {
+		cand, ok := c.candidate()
+		if !ok {
+			continue
+		}
+		trace(n, "trace: candidates: resolver %s found '%s'", c.Resolver, cand)
+		candidates = append(candidates, cand)
+	}
+	return
+}
+
+// commandArgv converts a resolver command from the resolver_commands option into the
+// arguments to run. A string is run by the shell, and a list is run as it is.
+func commandArgv(command interface{}) (argv []string, err error) {
+	switch c := command.(type) {
+	case string:
+		if strings.TrimSpace(c) == "" {
+			return nil, fmt.Errorf("the command is empty")
+		}
+		return []string{"sh", "-c", c}, nil
+	case []interface{}:
+		for _, arg := range c {
+			s, ok := arg.(string)
+			if !ok {
+				return nil, fmt.Errorf("the argument %v is not a string", arg)
+			}
+			argv = append(argv, s)
+		}
+		if len(argv) == 0 {
+			return nil, fmt.Errorf("the command is empty")
+		}
+		return argv, nil
+	}
+	return nil, fmt.Errorf("expected a string or a list but got %v", command)
+}
+
+// resolverCommandName names the resolver command `argv` in messages.
+func resolverCommandName(argv []string) string {
+	if len(argv) == 3 && argv[0] == "sh" && argv[1] == "-c" {
+		return argv[2]
+	}
+	return strings.Join(argv, " ")
+}
+
+// runCommands runs the resolver commands that haven't failed in parallel and returns the
+// candidates they found, in the order the commands are configured. A command that fails or
+// runs past the run's resolver_timeout is reported and skipped.
+func (r *resolverRun) runCommands(text string, col int) (found []resolverCandidate, err error) {
+	var commands [][]string
+	var indexes []int
+	for i, argv := range r.commands {
+		if !r.failed[i] {
+			commands = append(commands, argv)
+			indexes = append(indexes, i)
+		}
+	}
+	if len(commands) == 0 {
+		return
+	}
+
+	if r.req == nil {
+		r.req, err = r.n.resolverRequest(r.window, r.dir)
+		if err != nil {
+			return
+		}
+	}
+	req := *r.req
+	req.Line, req.Col = text, col
+
+	results := resolver.RunAll(r.ctx, commands, req)
+	if err = r.n.context().Err(); err != nil {
+		// The jump was canceled
+		return
+	}
+
+	for i, res := range results {
+		name := resolverCommandName(commands[i])
+		if res.Err != nil {
+			r.failed[indexes[i]] = true
+			logger.Warn("resolver command failed", "command", name, "err", res.Err)
+		}
+		if res.Err == context.DeadlineExceeded {
+			r.n.Echom("error: resolver command '%s' timed out", name)
+			continue
+		}
+		if res.Err != nil {
+			r.n.Echom("error: resolver command '%s': %v", name, res.Err)
+			continue
+		}
+		for _, cand := range res.Candidates {
+			found = append(found, resolverCandidate{Resolver: name, Text: cand.Text, Path: cand.Path, Line: cand.Line, Col: cand.Col})
+		}
+	}
+	return
+}
+
+// resolverRequest returns a request to the resolver commands describing the window
+// `window`, or the current window if `window` is -1, without the line and column. The
+// commands are run in the directory `dir`, or the window's directory if `dir` is "".
+func (n Basejump) resolverRequest(window int, dir string) (req *resolver.Request, err error) {
+	req = &resolver.Request{Cwd: dir}
+	if dir == "" {
+		req.Cwd, err = n.Cwd(window)
+		if err != nil {
+			return
+		}
+	}
+	var info struct {
+		Buffer   string `msgpack:"buffer"`
+		Filetype string `msgpack:"filetype"`
+	}
+	bufnr := "bufnr()"
+	if window != -1 {
+		bufnr = fmt.Sprintf("winbufnr(%d)", window)
+	}
+	err = n.nvim().Eval(fmt.Sprintf(`{'buffer': bufname(%[1]s), 'filetype': getbufvar(%[1]s, '&filetype')}`, bufnr), &info)
+	if err != nil {
+		return
+	}
+	req.Buffer, req.Filetype = info.Buffer, info.Filetype
+	return
+}
+
+// textAtCol returns the path or URL at the 1-based byte column `col` in `text`, which is a
+// line in the window `window` or the current window if `window` is -1. The custom resolvers
+// are consulted first, and if they find more than one candidate the first that exists is
+// chosen. If they find none the path is found using the pathchars option.
+func (n Basejump) textAtCol(text string, col, window int) (result candidate, err error) {
+	candidates, err := n.customCandidates(text, col, window)
+	if err != nil {
+		return
+	}
+	if len(candidates) > 0 {
+		return n.chooseCandidate(candidates, n.absInWindow(window))
+	}
+
+	result.text, err = n.PathInLine(text, col)
+	return
+}
+
+// resolveTexts chooses the path or URL to open from `texts`, which are each a whole path
+// or URL rather than a line to find one in. The custom resolvers are called with each text
+// and the column 1, and the texts they find nothing in are candidates themselves. The
+// first candidate that exists is chosen, or the first if none do. The texts are looked at
+// in order, and once a candidate that exists
+// is found the rest are not passed to the resolvers. Relative paths are relative to the
+// directory `dir`, or if it is "" to the directory of the window `window` or the current
+// window if it is -1. Resolver commands are run in the same directory.
+func (n Basejump) resolveTexts(texts []string, window int, dir string) (chosen candidate, err error) {
+	r := n.startResolvers(window, dir)
+	defer r.stop()
+
+	abs := n.absInWindow(window)
+	if dir != "" {
+		abs = absRelDir(dir)
+	}
+
+	for i, t := range texts {
+		var found []candidate
+		found, err = r.candidates(t, 1)
+		if err != nil {
+			return
+		}
+		if len(found) == 0 {
+			found = []candidate{{text: t}}
+		}
+
+		if len(texts) == 1 {
+			return n.chooseCandidate(found, abs)
+		}
+
+		if i == 0 {
+			// Chosen if none of the candidates exist
+			chosen = found[0]
+		}
+
+		existing, ok, err := n.firstExistingCandidate(found, abs)
+		if err != nil || ok {
+			return existing, err
+		}
+	}
+	trace(n, "trace: resolveTexts: none of the candidates exist")
+	return
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jeffwilliams/basejump/jump"
+)
+
+func TestResolverCandidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		candidate resolverCandidate
+		expected  candidate
+		ok        bool
+	}{
+		{"text", resolverCandidate{Text: "file.go:12:3"}, candidate{text: "file.go:12:3", custom: true}, true},
+		{"text wins", resolverCandidate{Text: "a.go", Path: "b.go", Line: 2}, candidate{text: "a.go", custom: true}, true},
+		{"path", resolverCandidate{Path: "/src/file.go"},
+			candidate{target: &jump.Target{Path: "/src/file.go"}, custom: true}, true},
+		{"path line and col", resolverCandidate{Path: "/src/file.go", Line: 12, Col: 3},
+			candidate{target: &jump.Target{Path: "/src/file.go", Line: 12, Col: 3}, custom: true}, true},
+		{"col without line", resolverCandidate{Path: "/src/file.go", Col: 3},
+			candidate{target: &jump.Target{Path: "/src/file.go"}, custom: true}, true},
+		{"path with colons and spaces", resolverCandidate{Path: "/src/a:b c.go", Line: 4},
+			candidate{target: &jump.Target{Path: "/src/a:b c.go", Line: 4}, custom: true}, true},
+		{"empty", resolverCandidate{Resolver: "r"}, candidate{}, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, ok := tc.candidate.candidate()
+			if ok != tc.ok || !reflect.DeepEqual(actual, tc.expected) {
+				t.Fatalf("expected %+v, %v but got %+v, %v", tc.expected, tc.ok, actual, ok)
+			}
+		})
+	}
+}
+
+func TestCandidateString(t *testing.T) {
+	tests := []struct {
+		candidate candidate
+		expected  string
+	}{
+		{candidate{text: "file.go:12:3"}, "file.go:12:3"},
+		{candidate{target: &jump.Target{Path: "/src/file.go"}}, "/src/file.go"},
+		{candidate{target: &jump.Target{Path: "/src/file.go", Line: 12}}, "/src/file.go:12"},
+		{candidate{target: &jump.Target{Path: "/src/file.go", Line: 12, Col: 3}}, "/src/file.go:12:3"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.expected, func(t *testing.T) {
+			if actual := tc.candidate.String(); actual != tc.expected {
+				t.Fatalf("expected '%s' but got '%s'", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestCommandArgv(t *testing.T) {
+	tests := []struct {
+		name     string
+		command  interface{}
+		expected []string
+		err      bool
+	}{
+		{"string", "~/bin/resolve --index", []string{"sh", "-c", "~/bin/resolve --index"}, false},
+		{"list", []interface{}{"resolve", "--index", "/tmp/idx"}, []string{"resolve", "--index", "/tmp/idx"}, false},
+		{"empty string", " ", nil, true},
+		{"empty list", []interface{}{}, nil, true},
+		{"list of numbers", []interface{}{"resolve", int64(1)}, nil, true},
+		{"number", int64(1), nil, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			argv, err := commandArgv(tc.command)
+			if tc.err {
+				if err == nil {
+					t.Fatalf("expected an error but got %v", argv)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("got error: %v", err)
+			}
+			if !reflect.DeepEqual(argv, tc.expected) {
+				t.Fatalf("expected %v but got %v", tc.expected, argv)
+			}
+		})
+	}
+}
+
+func TestResolveTextsRunsFailingCommandOnce(t *testing.T) {
+	n := embeddedBasejump(t)
+	nv := n.nvim()
+
+	dir := t.TempDir()
+	runs := filepath.Join(dir, "runs")
+	err := nv.SetVar("basejump", map[string]interface{}{
+		"history":           false,
+		"resolver_commands": []interface{}{"echo run >> '" + runs + "'; exit 1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := n.withConfig().resolveTexts([]string{"a.go", "b.go", "c.go"}, -1, dir)
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	if c.String() != "a.go" || c.custom {
+		t.Fatalf("expected the first text but got %+v", c)
+	}
+
+	data, err := os.ReadFile(runs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count := strings.Count(string(data), "run"); count != 1 {
+		t.Fatalf("expected the failing command to run once but it ran %d times", count)
+	}
+
+	var messages string
+	err = nv.Call("execute", &messages, "messages")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count := strings.Count(messages, "error: resolver command"); count != 1 {
+		t.Fatalf("expected the failure to be reported once but got:\n%s", messages)
+	}
+}
+
+func TestResolveTextsRunsCommandsInDir(t *testing.T) {
+	n := embeddedBasejump(t)
+
+	// The command only finds the file when it is run in the directory the text is relative to
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "found.go"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	err := n.nvim().SetVar("basejump", map[string]interface{}{
+		"history":           false,
+		"resolver_commands": []interface{}{`test -f found.go && echo '["found.go"]' || echo '[]'`},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := n.withConfig().resolveTexts([]string{"ticket-1"}, -1, dir)
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	if c.String() != "found.go" || !c.custom {
+		t.Fatalf("expected the resolver command to find found.go but got %+v", c)
+	}
+}
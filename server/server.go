@@ -0,0 +1,131 @@
+// Package server implements a simple protocol over a unix socket that lets programs
+// like terminal emulators and shell scripts ask basejump to open text like
+// main.go:12 in the nvim instance it is running in.
+//
+// Each connection carries a single request and its response, each encoded as a
+// line of JSON.
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Request asks for `Text` to be opened.
+type Request struct {
+	// Text is the path to open, optionally followed by a line and column like main.go:12:5,
+	// or a URL.
+	Text string `json:"text"`
+	// Cwd is the directory that a relative path in Text is relative to
+	Cwd string `json:"cwd"`
+	// Method is the open mode to use. If empty the configured one is used.
+	Method string `json:"method,omitempty"`
+}
+
+// Response is the result of a Request.
+type Response struct {
+	// Error describes why the request failed. It is empty on success.
+	Error string `json:"error,omitempty"`
+	// Path, Line and Col describe what was opened
+	Path string `json:"path,omitempty"`
+	Line int    `json:"line,omitempty"`
+	Col  int    `json:"col,omitempty"`
+}
+
+// Handler performs a Request.
+type Handler func(req Request) Response
+
+// timeout is how long a connection may take to send its request or read its response
+const timeout = 30 * time.Second
+
+// DefaultPath returns the path of the socket used when none is specified.
+func DefaultPath() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "basejump.sock")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("basejump-%d.sock", os.Getuid()))
+}
+
+// Listen listens on the unix socket `path`. A socket left behind by a previous
+// server that is no longer running is removed.
+func Listen(path string) (l net.Listener, err error) {
+	l, err = net.Listen("unix", path)
+	if err == nil {
+		return
+	}
+
+	if c, derr := net.Dial("unix", path); derr == nil {
+		c.Close()
+		return nil, fmt.Errorf("a server is already listening on %s", path)
+	}
+
+	if fi, serr := os.Lstat(path); serr != nil || fi.Mode()&os.ModeSocket == 0 {
+		return
+	}
+	os.Remove(path)
+	return net.Listen("unix", path)
+}
+
+// Serve accepts connections on `l` and calls `h` for each request, until `l` is closed.
+func Serve(l net.Listener, h Handler) error {
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go serveConn(c, h)
+	}
+}
+
+func serveConn(c net.Conn, h Handler) {
+	defer c.Close()
+	c.SetDeadline(time.Now().Add(timeout))
+
+	var req Request
+	var resp Response
+
+	line, err := bufio.NewReader(c).ReadBytes('\n')
+	if err == nil {
+		err = json.Unmarshal(line, &req)
+	}
+
+	if err != nil {
+		resp.Error = fmt.Sprintf("invalid request: %v", err)
+	} else {
+		resp = h(req)
+	}
+
+	json.NewEncoder(c).Encode(resp)
+}
+
+// Send sends the request `req` to the server listening on the unix socket `path`
+// and returns its response. A response that reports an error is returned as an error.
+func Send(path string, req Request) (resp Response, err error) {
+	c, err := net.DialTimeout("unix", path, timeout)
+	if err != nil {
+		return
+	}
+	defer c.Close()
+	c.SetDeadline(time.Now().Add(timeout))
+
+	err = json.NewEncoder(c).Encode(req)
+	if err != nil {
+		return
+	}
+
+	line, err := bufio.NewReader(c).ReadBytes('\n')
+	if err != nil {
+		return
+	}
+
+	err = json.Unmarshal(line, &resp)
+	if err == nil && resp.Error != "" {
+		err = fmt.Errorf("%s", resp.Error)
+	}
+	return
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func socketPath(t *testing.T) string {
+	// Socket paths are limited in length, so don't use t.TempDir()
+	dir, err := os.MkdirTemp("", "bj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return filepath.Join(dir, "s")
+}
+
+func TestSendAndServe(t *testing.T) {
+	path := socketPath(t)
+
+	l, err := Listen(path)
+	if err != nil {
+		t.Fatalf("Listen returned error: %v", err)
+	}
+	defer l.Close()
+
+	go Serve(l, func(req Request) Response {
+		if req.Text == "bad" {
+			return Response{Error: "bad request"}
+		}
+		return Response{Path: filepath.Join(req.Cwd, req.Text), Line: 12}
+	})
+
+	resp, err := Send(path, Request{Text: "main.go", Cwd: "/src"})
+	if err != nil {
+		t.Fatalf("Send returned error: %v", err)
+	}
+	if resp.Path != "/src/main.go" || resp.Line != 12 {
+		t.Fatalf("Unexpected response: %v", resp)
+	}
+
+	_, err = Send(path, Request{Text: "bad"})
+	if err == nil || err.Error() != "bad request" {
+		t.Fatalf("Expected the error 'bad request' but got %v", err)
+	}
+}
+
+func TestInvalidRequest(t *testing.T) {
+	path := socketPath(t)
+
+	l, err := Listen(path)
+	if err != nil {
+		t.Fatalf("Listen returned error: %v", err)
+	}
+	defer l.Close()
+
+	go Serve(l, func(req Request) Response { return Response{} })
+
+	c, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	c.Write([]byte("not json\n"))
+
+	var resp Response
+	err = json.NewDecoder(c).Decode(&resp)
+	if err != nil {
+		t.Fatalf("Reading the response returned error: %v", err)
+	}
+	if resp.Error == "" {
+		t.Fatalf("Expected an error response but got %v", resp)
+	}
+}
+
+func TestListenRemovesStaleSocket(t *testing.T) {
+	path := socketPath(t)
+
+	l, err := Listen(path)
+	if err != nil {
+		t.Fatalf("Listen returned error: %v", err)
+	}
+
+	_, err = Listen(path)
+	if err == nil {
+		t.Fatalf("Expected an error listening while another server is running")
+	}
+
+	// Leave the socket file behind, like a server that crashed
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	l.Close()
+
+	l, err = Listen(path)
+	if err != nil {
+		t.Fatalf("Listen on a stale socket returned error: %v", err)
+	}
+	l.Close()
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/jeffwilliams/basejump/jump"
+	"github.com/neovim/go-client/nvim"
+)
+
+// targetServer is a connection to another nvim instance that jumps are made in.
+type targetServer struct {
+	// setting is the value of g:basejump_target_server the connection was made for
+	setting string
+	addr    string
+	nv      *nvim.Nvim
+	// state is the state of jumps made in the target server
+	state jumpState
+}
+
+const (
+	// targetServerEnv makes jumps in the nvim instance named by $NVIM in the
+	// environment of this nvim instance; that is, the nvim that this one is
+	// running in a terminal of.
+	targetServerEnv = "$NVIM"
+	// targetServerDiscover makes jumps in another nvim instance found by looking
+	// for sockets in the directories nvim creates them in.
+	targetServerDiscover = "discover"
+)
+
+// targetServer returns a Basejump that makes jumps in the nvim instance set by
+// g:basejump_target_server, connecting to it if necessary. If jumps should be
+// made in this nvim instance the result is nil.
+func (n Basejump) targetServer() (t *Basejump, err error) {
+	if n.target != nil {
+		// Already operating on the target server
+		return
+	}
+
+	setting := n.config().TargetServer
+	if setting == "" {
+		return
+	}
+
+	s := n.state.server
+	if s == nil || s.setting != setting || !n.alive(s) {
+		if s != nil {
+			s.nv.Close()
+			n.state.server = nil
+		}
+
+		s, err = n.connectTargetServer(setting)
+		if err != nil {
+			return
+		}
+		n.state.server = s
+	}
+
+	trace(n, "trace: targetServer: making jump in %s", s.addr)
+	// The jump carries on in the target, so it keeps this jump's context and configuration
+	return &Basejump{P: n.P, state: &s.state, target: s.nv, ctx: n.ctx, cfg: n.cfg}, nil
+}
+
+// connectTargetServer connects to the nvim instance described by `setting`,
+// which is a value of g:basejump_target_server.
+func (n Basejump) connectTargetServer(setting string) (s *targetServer, err error) {
+	nv := n.nvim()
+
+	var own string
+	err = nv.Eval("v:servername", &own)
+	if err != nil {
+		return
+	}
+
+	var addrs []string
+	switch setting {
+	case targetServerEnv:
+		var addr string
+		err = nv.Eval("$NVIM", &addr)
+		if err != nil {
+			return
+		}
+		if addr == "" || addr == own {
+			return nil, fmt.Errorf("$NVIM doesn't name another nvim instance")
+		}
+		addrs = []string{addr}
+	case targetServerDiscover:
+		ctx, cancel := n.statContext()
+		defer cancel()
+
+		for _, addr := range discoverServers(os.Getenv("XDG_RUNTIME_DIR"), os.TempDir()) {
+			var same bool
+			same, err = jump.SamePath(ctx, addr, own)
+			if err != nil {
+				return nil, n.statError(err, addr)
+			}
+			if !same {
+				addrs = append(addrs, addr)
+			}
+		}
+		if len(addrs) == 0 {
+			return nil, fmt.Errorf("no other nvim instance found")
+		}
+	default:
+		addrs = []string{setting}
+	}
+
+	for _, addr := range addrs {
+		var v *nvim.Nvim
+		v, err = n.dial(addr)
+		if err != nil {
+			trace(n, "trace: connectTargetServer: can't connect to %s: %v", addr, err)
+			continue
+		}
+		return &targetServer{setting: setting, addr: addr, nv: v}, nil
+	}
+
+	return nil, fmt.Errorf("can't connect to target server %s: %v", addrs[len(addrs)-1], err)
+}
+
+// dial connects to the nvim instance listening at `addr`. It fails if connecting takes
+// longer than g:basejump_stat_timeout, so that a stale socket can't hang the jump.
+func (n Basejump) dial(addr string) (v *nvim.Nvim, err error) {
+	ctx, cancel := n.statContext()
+	defer cancel()
+
+	v, err = nvim.Dial(addr, nvim.DialContext(ctx))
+	if errors.Is(err, context.DeadlineExceeded) && n.context().Err() == nil {
+		err = fmt.Errorf("timed out connecting to %s", addr)
+	}
+	return
+}
+
+// alive returns true if the target server `s` responds within g:basejump_stat_timeout.
+func (n Basejump) alive(s *targetServer) bool {
+	ctx, cancel := n.statContext()
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		var i int
+		done <- s.nv.Eval("1", &i)
+	}()
+
+	select {
+	case err := <-done:
+		return err == nil
+	case <-ctx.Done():
+		// The caller closes the connection, which ends the call
+		trace(n, "trace: alive: %s didn't respond", s.addr)
+		return false
+	}
+}
+
+// raise asks the GUI of the target server, if it has one, to bring its window to the front.
+func (n Basejump) raise() error {
+	return n.nvim().Command("call rpcnotify(0, 'Gui', 'Foreground')")
+}
+
+// discoverServers returns the addresses of the sockets of running nvim instances, most
+// recently started first. `runtimeDir` is $XDG_RUNTIME_DIR and `tmpDir` is the system's
+// temporary directory, which are where nvim creates its sockets.
+func discoverServers(runtimeDir, tmpDir string) []string {
+	var patterns []string
+	if runtimeDir != "" {
+		// nvim 0.9 and later with XDG_RUNTIME_DIR set
+		patterns = append(patterns, filepath.Join(runtimeDir, "nvim.*.0"))
+	}
+	patterns = append(patterns,
+		// nvim 0.9 and later without XDG_RUNTIME_DIR
+		filepath.Join(tmpDir, "nvim.*", "*", "nvim.*.0"),
+		// Earlier versions
+		filepath.Join(tmpDir, "nvim*", "0"),
+	)
+
+	type server struct {
+		addr    string
+		started int64
+	}
+
+	var servers []server
+	for _, pattern := range patterns {
+		matches, _ := filepath.Glob(pattern)
+		for _, m := range matches {
+			fi, err := os.Stat(m)
+			if err != nil || fi.Mode()&os.ModeSocket == 0 {
+				continue
+			}
+			servers = append(servers, server{m, fi.ModTime().UnixNano()})
+		}
+	}
+
+	sort.SliceStable(servers, func(i, j int) bool {
+		return servers[i].started > servers[j].started
+	})
+
+	addrs := make([]string, len(servers))
+	for i, s := range servers {
+		addrs[i] = s.addr
+	}
+	return addrs
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDiscoverServers(t *testing.T) {
+	// Socket paths are limited in length, so don't use t.TempDir()
+	dir, err := os.MkdirTemp("", "bj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	runtimeDir := filepath.Join(dir, "run")
+	tmpDir := filepath.Join(dir, "tmp")
+	for _, d := range []string{runtimeDir, filepath.Join(tmpDir, "nvim.user", "x1"), filepath.Join(tmpDir, "nvimAbc")} {
+		if err := os.MkdirAll(d, 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	listen := func(path string, age time.Duration) {
+		l, err := net.Listen("unix", path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { l.Close() })
+		mtime := time.Now().Add(-age)
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	old := filepath.Join(tmpDir, "nvimAbc", "0")
+	newer := filepath.Join(runtimeDir, "nvim.100.0")
+	newest := filepath.Join(tmpDir, "nvim.user", "x1", "nvim.200.0")
+	listen(old, 3*time.Hour)
+	listen(newer, 2*time.Hour)
+	listen(newest, time.Hour)
+
+	// Not a socket, so not a server
+	if err := os.WriteFile(filepath.Join(runtimeDir, "nvim.300.0"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	addrs := discoverServers(runtimeDir, tmpDir)
+	expected := []string{newest, newer, old}
+	if len(addrs) != len(expected) {
+		t.Fatalf("expected %v but got %v", expected, addrs)
+	}
+	for i := range expected {
+		if addrs[i] != expected[i] {
+			t.Fatalf("expected %v but got %v", expected, addrs)
+		}
+	}
+}
+
+func TestAliveTimesOut(t *testing.T) {
+	// Socket paths are limited in length, so don't use t.TempDir()
+	dir, err := os.MkdirTemp("", "bj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// A server that accepts connections but never answers, like a stopped nvim
+	sock := filepath.Join(dir, "nvim.sock")
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { c.Close() })
+		}
+	}()
+
+	cfg := defaultConfig()
+	cfg.StatTimeout = 50
+	n := Basejump{cfg: &cfg}
+
+	v, err := n.dial(sock)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer v.Close()
+
+	start := time.Now()
+	if n.alive(&targetServer{addr: sock, nv: v}) {
+		t.Fatalf("expected a server that doesn't answer not to be alive")
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("alive took %v", d)
+	}
+}
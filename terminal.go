@@ -0,0 +1,91 @@
+package main
+
+// inTerminal returns true if the current window is a terminal window and
+// g:basejump_terminal_use_editor is set, meaning that jumps should be
+// opened in an editor window instead of splitting the terminal.
+func (n Basejump) inTerminal() (b bool, err error) {
+	if !n.config().TerminalUseEditor {
+		return
+	}
+
+	var buftype string
+	err = n.nvim().Eval("&buftype", &buftype)
+	b = buftype == "terminal"
+	return
+}
+
+// gotoEditorWindow makes the window that jumps from a terminal are opened in the current
+// window. This is the window designated by BasejumpSetEditorWindow() if it is in the
+// current tab, otherwise the most recently used non-terminal window in the tab. If there
+// is no such window `found` is false.
+func (n Basejump) gotoEditorWindow() (found bool, err error) {
+	nv := n.nvim()
+
+	var winID int
+	err = nv.Eval("get(t:, 'basejump_editor_win', 0)", &winID)
+	if err != nil {
+		return
+	}
+
+	if winID != 0 {
+		var winNr int
+		err = nv.Call("win_id2win", &winNr, winID)
+		if err != nil {
+			return
+		}
+		if winNr == 0 {
+			trace(n, "trace: gotoEditorWindow: designated window %d is not in this tab", winID)
+			winID = 0
+		}
+	}
+
+	if winID == 0 {
+		winID, err = n.lastEditorWindow()
+		if err != nil || winID == 0 {
+			return
+		}
+	}
+
+	trace(n, "trace: gotoEditorWindow: using window %d", winID)
+	var ok int
+	err = nv.Call("win_gotoid", &ok, winID)
+	found = err == nil && ok == 1
+	return
+}
+
+// lastEditorWindow returns the id of the most recently used non-terminal window in the
+// current tab, or 0 if there is none. This is the previous window if it is not a terminal,
+// otherwise the window whose buffer was most recently used.
+func (n Basejump) lastEditorWindow() (winID int, err error) {
+	nv := n.nvim()
+
+	// Each window is [winid, lastused, isprevious]
+	var wins [][3]int
+	err = nv.Eval(`map(filter(getwininfo(), {_, w -> w.tabnr == tabpagenr() && !w.terminal && win_gettype(w.winid) == ''}),`+
+		` {_, w -> [w.winid, getbufinfo(w.bufnr)[0].lastused, w.winnr == winnr('#')]})`, &wins)
+	if err != nil {
+		return
+	}
+
+	lastUsed := -1
+	for _, w := range wins {
+		if w[2] != 0 {
+			return w[0], nil
+		}
+		if w[1] > lastUsed {
+			winID, lastUsed = w[0], w[1]
+		}
+	}
+	return
+}
+
+// terminalKeepsFocus returns true if the current window is a terminal window
+// whose jumps are opened in an editor window, and g:basejump_terminal_keep_focus
+// is set so the cursor should stay in the terminal.
+func (n Basejump) terminalKeepsFocus() (b bool, err error) {
+	if !n.config().TerminalKeepFocus {
+		return
+	}
+
+	return n.inTerminal()
+}
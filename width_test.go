@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRuneWidth(t *testing.T) {
+	tests := []struct {
+		r     rune
+		vcol  int
+		width int
+	}{
+		{'a', 1, 1},
+		{'é', 1, 1},
+		{'\t', 1, 8},
+		{'\t', 3, 6},
+		{'日', 1, 2},
+		{'한', 1, 2},
+		{'Ａ', 1, 2},
+		{'😀', 1, 2},
+		{'〿', 1, 1},
+		{'→', 1, 1},
+	}
+	for _, tc := range tests {
+		t.Run(fmt.Sprintf("%q@%d", tc.r, tc.vcol), func(t *testing.T) {
+			w := runeWidth(tc.r, tc.vcol, 8)
+			if w != tc.width {
+				t.Fatalf("expected %d but got %d", tc.width, w)
+			}
+		})
+	}
+}